@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+
+	cmdinterpreter "github.com/olbrichattila/qreview/internal/cmd-interpreter"
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
+	"github.com/olbrichattila/qreview/internal/prcheck"
+	"github.com/olbrichattila/qreview/internal/review"
+)
+
+// getChecker returns the publisher of the check run and the PR URL if -check or GITHUB_CHECK asks for it, nil otherwise.
+// GITHUB_CHECK is ignored by local reviews
+func getChecker(env env.EnvironmentManager) (prcheck.Publisher, string, error) {
+	flagged := cmdinterpreter.HasFlag(cmdinterpreter.FlagCheck)
+	if !flagged && !env.GithubCheck() {
+		return nil, "", nil
+	}
+
+	kind, prURL, ok, err := pr.FromCommandLine(env)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !ok {
+		if flagged {
+			return nil, "", fmt.Errorf("-%s needs a pull request, set -%s=<PR URL>", cmdinterpreter.FlagCheck, cmdinterpreter.FlagPR)
+		}
+		return nil, "", nil
+	}
+
+	checker, err := prcheck.New(env, kind)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return checker, prURL, nil
+}
+
+// collectAnnotations records the findings of the analysis and the reviewed file for the check run.
+// Only the review definitions, which comment on the PR, count, the prose of documentation definitions is not a finding
+func (c *comm) collectAnnotations(j job, analysis review.Analysis) {
+	if c.checker == nil || analysis.Skipped || !j.reviewer.CommentsOnPR() {
+		return
+	}
+
+	c.checkedFiles[analysis.FileName] = true
+	for _, finding := range analysis.Review.Findings {
+		c.annotations = append(c.annotations, prcheck.Annotation{
+			Definition: j.reviewer.Name(),
+			FilePath:   analysis.FileName,
+			Finding:    finding,
+		})
+	}
+}
+
+// publishCheck publishes the findings and the failures of the run as a check run on the PR
+func (c *comm) publishCheck() error {
+	if c.checker == nil {
+		return nil
+	}
+
+	result := prcheck.Result{
+		Annotations: c.annotations,
+		Files:       len(c.checkedFiles),
+		Failures:    len(c.failures),
+		FailOn:      c.failOn,
+	}
+
+	fmt.Printf("PR check: %s, %s\n", result.Conclusion(), result.Title())
+	return c.checker.Publish(c.prURL, result)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/olbrichattila/qreview/internal/report"
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+const (
+	stageAnalyze = "analyze"
+	stagePublish = "publish"
+	stageSummary = "summary"
+
+	failuresReportName = "failures"
+)
+
+// failure is a review step which failed while running in continue on error mode
+type failure struct {
+	fileName   string
+	definition string
+	stage      string
+	err        error
+}
+
+// FailuresError is returned by Execute when the review went on after errors, but some steps failed
+type FailuresError struct {
+	Count int
+}
+
+func (e *FailuresError) Error() string {
+	return fmt.Sprintf("%d review step(s) failed", e.Count)
+}
+
+// printFailures displays the failures as a table on the console
+func printFailures(failures []failure) {
+	fmt.Printf("\n%d review step(s) failed:\n", len(failures))
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tDEFINITION\tSTAGE\tERROR")
+	for _, f := range failures {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.fileName, f.definition, f.stage, singleLine(f.err.Error()))
+	}
+	w.Flush()
+}
+
+// saveFailures writes the failures as a markdown table into the report folder, with the usual reporters
+func saveFailures(reportFolder string, failures []failure) error {
+	var md strings.Builder
+	md.WriteString("# Review failures\n\n")
+	md.WriteString("| File | Definition | Stage | Error |\n")
+	md.WriteString("| --- | --- | --- | --- |\n")
+	for _, f := range failures {
+		md.WriteString(fmt.Sprintf(
+			"| %s | %s | %s | %s |\n",
+			escapeCell(f.fileName),
+			escapeCell(f.definition),
+			f.stage,
+			escapeCell(singleLine(f.err.Error())),
+		))
+	}
+
+	for _, kind := range []report.Kind{report.KindHTML, report.KindSave} {
+		reporter, err := report.New(kind, reportFolder, failuresReportName)
+		if err != nil {
+			return err
+		}
+
+		if err := reporter.Report(report.Entry{FileName: failuresReportName, Review: reviewparser.Response{Raw: md.String()}}); err != nil {
+			return err
+		}
+
+		if err := reporter.Summary("index"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func singleLine(str string) string {
+	return strings.Join(strings.Fields(str), " ")
+}
+
+func escapeCell(str string) string {
+	return strings.ReplaceAll(str, "|", "\\|")
+}
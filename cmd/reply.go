@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cmdinterpreter "github.com/olbrichattila/qreview/internal/cmd-interpreter"
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
+	"github.com/olbrichattila/qreview/internal/prcomment"
+	"github.com/olbrichattila/qreview/internal/review"
+)
+
+const (
+	promptReply = "You are qreview, an AI code reviewer, answering in a review thread of a pull request. " +
+		"Reply to the last comment addressing you, briefly and in markdown, based on the code and the diff below. " +
+		"If you are asked whether an issue is fixed, check it in the current code. " +
+		"Do not repeat the thread, and say so if the question cannot be answered from the code.\n\n"
+
+	replyContextLines = 20 // Lines of the current file sent above and below the line of the thread
+)
+
+// NewReply creates the command answering the comments which mention qreview in the review threads of the PR
+func NewReply(env env.EnvironmentManager) (CommandInterpreter, error) {
+	if env == nil {
+		return nil, fmt.Errorf("environment manager should not be nil")
+	}
+
+	kind, prURL, ok, err := pr.FromCommandLine(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("-%s needs a pull request, set -%s=<PR URL>", cmdinterpreter.FlagReply, cmdinterpreter.FlagPR)
+	}
+
+	if err := pr.ValidateURL(kind, prURL); err != nil {
+		return nil, err
+	}
+
+	pullRequest := pr.New(env, kind)
+	threads, ok := pullRequest.(pr.ThreadReader)
+	if !ok {
+		return nil, fmt.Errorf("replying is not supported on %s pull requests", kind)
+	}
+
+	var replier prcomment.Replier
+	if cmdinterpreter.HasFlag(cmdinterpreter.FlagComment) {
+		commenter, err := prcomment.New(env, kind)
+		if err != nil {
+			return nil, err
+		}
+
+		if replier, ok = commenter.(prcomment.Replier); !ok {
+			return nil, fmt.Errorf("replying is not supported on %s pull requests", kind)
+		}
+	}
+
+	return &replyComm{
+		prURL:       prURL,
+		pullRequest: pullRequest,
+		threads:     threads,
+		replier:     replier,
+		model:       review.NewModel(env),
+		mention:     env.ReplyMention(),
+	}, nil
+}
+
+// replyComm answers the review threads, the replies are only printed if the replier is nil
+type replyComm struct {
+	prURL       string
+	pullRequest pr.PullRequest
+	threads     pr.ThreadReader
+	replier     prcomment.Replier
+	model       review.Model
+	mention     string
+}
+
+func (r *replyComm) Execute() error {
+	threads, err := r.threads.ReviewThreads(r.prURL)
+	if err != nil {
+		return fmt.Errorf("failed to read the review threads: %w", err)
+	}
+
+	replied, failed := 0, 0
+	for _, thread := range threads {
+		comment, ok := prcomment.Mention(thread, r.mention)
+		if !ok {
+			continue
+		}
+
+		fmt.Printf("Replying on PR File: %s, line %d\n", thread.FilePath, thread.Line)
+		answer, err := r.answer(thread)
+		if err == nil && r.replier != nil {
+			err = r.replier.Reply(r.prURL, thread, comment.ID, answer)
+		}
+
+		if err != nil {
+			// Skip error not to leave the other threads unanswered
+			fmt.Printf("failed to reply. %s: File: %s\n", err, thread.FilePath)
+			failed++
+			continue
+		}
+
+		if r.replier == nil {
+			fmt.Println(answer)
+		}
+		replied++
+	}
+
+	fmt.Printf("PR threads: %d replies, %d failed\n", replied, failed)
+	if failed > 0 {
+		return fmt.Errorf("failed to reply to %d review threads", failed)
+	}
+
+	return nil
+}
+
+// answer asks the model to reply to the thread, with the code around its line and its diff hunk
+func (r *replyComm) answer(thread pr.Thread) (string, error) {
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("File: %s\n", thread.FilePath))
+	if thread.Line > 0 {
+		content.WriteString(fmt.Sprintf("Line of the thread: %d\n", thread.Line))
+	}
+
+	if code, err := r.pullRequest.GetPRFileContent(r.prURL, thread.FilePath); err == nil {
+		content.WriteString("\nCurrent code, with line numbers:\n" + numberedLines(code, thread.Line) + "\n")
+	}
+
+	if thread.DiffHunk != "" {
+		content.WriteString("\nDiff:\n" + thread.DiffHunk + "\n")
+	}
+
+	content.WriteString("\nThread:\n" + prcomment.Conversation(thread) + "\n")
+
+	response, err := r.model.Complete(context.Background(), review.Request{
+		Prompt:  promptReply,
+		Content: content.String(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	answer := strings.TrimSpace(response.Text)
+	if answer == "" {
+		return "", fmt.Errorf("the model returned an empty answer")
+	}
+
+	return answer, nil
+}
+
+// numberedLines returns the lines of the code around the line with their numbers, the whole code up to the limit if line is 0
+func numberedLines(code string, line int) string {
+	lines := strings.Split(strings.TrimRight(code, "\n"), "\n")
+	from, to := 1, min(len(lines), 2*replyContextLines+1)
+	if line > 0 {
+		from, to = max(1, line-replyContextLines), min(len(lines), line+replyContextLines)
+	}
+
+	var numbered strings.Builder
+	for i := from; i <= to; i++ {
+		numbered.WriteString(fmt.Sprintf("%d: %s\n", i, lines[i-1]))
+	}
+
+	return numbered.String()
+}
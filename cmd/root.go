@@ -2,14 +2,18 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
+	"sync"
 
+	cmdinterpreter "github.com/olbrichattila/qreview/internal/cmd-interpreter"
 	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/prcheck"
 	"github.com/olbrichattila/qreview/internal/review"
 	"github.com/olbrichattila/qreview/internal/source"
 )
 
 // New creates a new command line interpreter
-func New(env env.EnvironmentManager, reviewers []review.Reviewer) (CommandInterpreter, error) {
+func New(env env.EnvironmentManager, reviewers []review.Reviewer, reportFolder string) (CommandInterpreter, error) {
 	// validation
 
 	if env == nil {
@@ -22,15 +26,37 @@ func New(env env.EnvironmentManager, reviewers []review.Reviewer) (CommandInterp
 		}
 	}
 
+	concurrency, err := getConcurrency(env)
+	if err != nil {
+		return nil, err
+	}
+
+	failOn, err := getFailOn(env)
+	if err != nil {
+		return nil, err
+	}
+
 	newSource, err := source.New(env)
 	if err != nil {
 		return nil, err
 	}
 
+	checker, prURL, err := getChecker(env)
+	if err != nil {
+		return nil, err
+	}
+
 	return &comm{
-		env:       env,
-		reviewers: reviewers,
-		source:    newSource,
+		env:             env,
+		reviewers:       reviewers,
+		source:          newSource,
+		reportFolder:    reportFolder,
+		concurrency:     concurrency,
+		continueOnError: env.ContinueOnError() || cmdinterpreter.HasFlag(cmdinterpreter.FlagContinue),
+		failOn:          failOn,
+		checker:         checker,
+		prURL:           prURL,
+		checkedFiles:    map[string]bool{},
 	}, nil
 }
 
@@ -39,9 +65,32 @@ type CommandInterpreter interface {
 }
 
 type comm struct {
-	env       env.EnvironmentManager
-	reviewers []review.Reviewer
-	source    source.Source
+	env             env.EnvironmentManager
+	reviewers       []review.Reviewer
+	source          source.Source
+	reportFolder    string
+	concurrency     int
+	continueOnError bool
+	failures        []failure
+	failOn          string
+	blocking        []blockingFinding
+	checker         prcheck.Publisher // Nil if no check run is published
+	prURL           string
+	annotations     []prcheck.Annotation
+	checkedFiles    map[string]bool
+}
+
+// job is a single file reviewed by a single reviewer, index is the order it has to be published in
+type job struct {
+	index    int
+	fileName string
+	reviewer review.Reviewer
+}
+
+type jobResult struct {
+	job      job
+	analysis review.Analysis
+	err      error
 }
 
 func (c *comm) Execute() error {
@@ -51,32 +100,123 @@ func (c *comm) Execute() error {
 	}
 
 	if files == nil || len(files) == 0 {
+		if err := c.publishCheck(); err != nil {
+			return fmt.Errorf("failed to publish the PR check: %w", err)
+		}
 		return nil
 	}
 
+	jobs := []job{}
 	for _, file := range files {
 		if !c.hasExt(file) {
 			continue
 		}
 
-		err := c.executeReview(file)
-		if err != nil {
-			return fmt.Errorf("failed to execute review: %w", err)
+		for _, reviewer := range c.reviewers {
+			jobs = append(jobs, job{index: len(jobs), fileName: file, reviewer: reviewer})
 		}
 	}
 
-	return c.generateReportSummary()
+	if err := c.executeReviews(jobs); err != nil {
+		return fmt.Errorf("failed to execute review: %w", err)
+	}
+
+	if err := review.SubmitPRComments(c.failOn); err != nil {
+		return fmt.Errorf("failed to submit the PR review: %w", err)
+	}
+
+	if err := c.generateReportSummary(); err != nil {
+		return err
+	}
+
+	if err := c.publishCheck(); err != nil {
+		return fmt.Errorf("failed to publish the PR check: %w", err)
+	}
+
+	failuresErr := c.reportFailures()
+	thresholdErr := c.reportBlocking()
+	if failuresErr != nil {
+		return failuresErr
+	}
+
+	return thresholdErr
 }
 
 func (c *comm) hasExt(fileName string) bool {
 	return c.env.ShouldProcessFile(fileName)
 }
 
-func (c *comm) executeReview(fileName string) error {
-	fmt.Printf("Reviewing %s...\n", fileName)
-	for _, reviewer := range c.reviewers {
-		if err := reviewer.AnalyzeCode(fileName); err != nil {
-			return fmt.Errorf("failed to analyze file: %w", err)
+// executeReviews analyzes the jobs on a bounded worker pool, and publishes the results
+// in the original order, so reports and PR comments do not depend on which model answered first
+func (c *comm) executeReviews(jobs []job) error {
+	jobCh := make(chan job)
+	resultCh := make(chan jobResult)
+	done := make(chan struct{})
+	defer close(done)
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				fmt.Printf("Reviewing %s (%s)...\n", j.fileName, j.reviewer.Name())
+				analysis, err := j.reviewer.Analyze(j.fileName)
+				select {
+				case resultCh <- jobResult{job: j, analysis: analysis, err: err}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobCh)
+		for _, j := range jobs {
+			select {
+			case jobCh <- j:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	pending := make(map[int]jobResult)
+	next := 0
+	for result := range resultCh {
+		pending[result.job.index] = result
+		for {
+			current, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if current.err != nil {
+				err := fmt.Errorf("failed to analyze file: %w", current.err)
+				if !c.collect(current.job, stageAnalyze, err) {
+					return err
+				}
+				continue
+			}
+
+			if err := current.job.reviewer.Publish(current.analysis); err != nil {
+				err = fmt.Errorf("failed to publish review of %s: %w", current.job.fileName, err)
+				if !c.collect(current.job, stagePublish, err) {
+					return err
+				}
+				continue
+			}
+
+			c.collectBlocking(current.job, current.analysis)
+			c.collectAnnotations(current.job, current.analysis)
 		}
 	}
 
@@ -86,9 +226,88 @@ func (c *comm) executeReview(fileName string) error {
 func (c *comm) generateReportSummary() error {
 	for _, reviewer := range c.reviewers {
 		if err := reviewer.Summary(); err != nil {
-			return err
+			if !c.collect(job{fileName: "-", reviewer: reviewer}, stageSummary, err) {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
+
+// collect records the failure if the review should continue on errors, returns false if it should stop instead
+func (c *comm) collect(j job, stage string, err error) bool {
+	if !c.continueOnError {
+		return false
+	}
+
+	fmt.Println(err)
+	c.failures = append(c.failures, failure{
+		fileName:   j.fileName,
+		definition: j.reviewer.Name(),
+		stage:      stage,
+		err:        err,
+	})
+
+	return true
+}
+
+// reportFailures prints and saves the collected failures, and returns an error if there was any
+func (c *comm) reportFailures() error {
+	if len(c.failures) == 0 {
+		return nil
+	}
+
+	printFailures(c.failures)
+	if err := saveFailures(c.reportFolder, c.failures); err != nil {
+		return fmt.Errorf("failed to save the failure report: %w", err)
+	}
+
+	return &FailuresError{Count: len(c.failures)}
+}
+
+// collectBlocking records the findings of the analysis at or above the fail on severity.
+// Like the check run annotations, only the review definitions, which comment on the PR, count
+func (c *comm) collectBlocking(j job, analysis review.Analysis) {
+	if c.failOn == "" || !j.reviewer.CommentsOnPR() {
+		return
+	}
+
+	for _, finding := range analysis.Review.Findings {
+		if !finding.AtLeast(c.failOn) {
+			continue
+		}
+
+		if finding.File == "" {
+			finding.File = analysis.FileName
+		}
+
+		c.blocking = append(c.blocking, blockingFinding{definition: j.reviewer.Name(), finding: finding})
+	}
+}
+
+// reportBlocking prints the findings at or above the fail on severity, and returns an error if there was any
+func (c *comm) reportBlocking() error {
+	if len(c.blocking) == 0 {
+		return nil
+	}
+
+	printBlocking(c.failOn, c.blocking)
+
+	return &ThresholdError{Severity: c.failOn, Count: len(c.blocking)}
+}
+
+// getConcurrency returns the -concurrency flag if set, otherwise the CONCURRENCY environment variable
+func getConcurrency(env env.EnvironmentManager) (int, error) {
+	if !cmdinterpreter.HasFlag(cmdinterpreter.FlagConcurrency) {
+		return env.Concurrency(), nil
+	}
+
+	value, _ := cmdinterpreter.Flag(cmdinterpreter.FlagConcurrency)
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 {
+		return 0, fmt.Errorf("invalid -%s=%s, should be a positive number", cmdinterpreter.FlagConcurrency, value)
+	}
+
+	return concurrency, nil
+}
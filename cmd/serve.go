@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/server"
+)
+
+// NewServe creates the command running the webhook server, each job runs the qreview executable again
+func NewServe(env env.EnvironmentManager) (CommandInterpreter, error) {
+	if env == nil {
+		return nil, fmt.Errorf("environment manager should not be nil")
+	}
+
+	runner, err := server.NewExecRunner()
+	if err != nil {
+		return nil, err
+	}
+
+	webhookServer, err := server.New(env, runner)
+	if err != nil {
+		return nil, err
+	}
+
+	return &serveComm{server: webhookServer, addr: env.ServeAddr()}, nil
+}
+
+type serveComm struct {
+	server *server.Server
+	addr   string
+}
+
+// Execute serves until the process is interrupted or terminated
+func (s *serveComm) Execute() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return s.server.Run(ctx, s.addr)
+}
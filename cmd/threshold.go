@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	cmdinterpreter "github.com/olbrichattila/qreview/internal/cmd-interpreter"
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+// blockingFinding is a finding at or above the fail on severity
+type blockingFinding struct {
+	definition string
+	finding    reviewparser.Finding
+}
+
+// ThresholdError is returned by Execute when there are findings at or above the fail on severity
+type ThresholdError struct {
+	Severity string
+	Count    int
+}
+
+func (e *ThresholdError) Error() string {
+	return fmt.Sprintf("%d finding(s) of severity %s or above", e.Count, e.Severity)
+}
+
+// getFailOn returns the -fail-on flag if set, otherwise the FAIL_ON environment variable
+func getFailOn(env env.EnvironmentManager) (string, error) {
+	failOn := env.FailOn()
+	if cmdinterpreter.HasFlag(cmdinterpreter.FlagFailOn) {
+		value, _ := cmdinterpreter.Flag(cmdinterpreter.FlagFailOn)
+		failOn = strings.ToLower(strings.TrimSpace(value))
+	}
+
+	if failOn != "" && reviewparser.SeverityRank(failOn) == 0 {
+		return "", fmt.Errorf(
+			"invalid -%s=%s, should be one of %s",
+			cmdinterpreter.FlagFailOn,
+			failOn,
+			strings.Join(reviewparser.Severities, ", "),
+		)
+	}
+
+	return failOn, nil
+}
+
+// printBlocking displays the findings which make the review fail as a table on the console
+func printBlocking(severity string, blocking []blockingFinding) {
+	fmt.Printf("\n%d finding(s) of severity %s or above:\n", len(blocking), severity)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tLINE\tSEVERITY\tDEFINITION\tMESSAGE")
+	for _, b := range blocking {
+		fmt.Fprintf(
+			w,
+			"%s\t%d\t%s\t%s\t%s\n",
+			b.finding.File,
+			b.finding.StartLine,
+			b.finding.Severity,
+			b.definition,
+			truncate(singleLine(b.finding.Message), 80),
+		)
+	}
+	w.Flush()
+}
+
+func truncate(str string, length int) string {
+	runes := []rune(str)
+	if len(runes) <= length {
+		return str
+	}
+
+	return string(runes[:length-3]) + "..."
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/review"
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+// fakeReviewer is a definition, commenting on the PR or not
+type fakeReviewer struct {
+	review.Reviewer
+	name         string
+	commentsOnPR bool
+}
+
+func (r fakeReviewer) Name() string       { return r.name }
+func (r fakeReviewer) CommentsOnPR() bool { return r.commentsOnPR }
+
+func TestCollectBlockingCountsOnlyReviewDefinitions(t *testing.T) {
+	c := &comm{failOn: "major"}
+	analysis := review.Analysis{
+		FileName: "main.go",
+		Review: reviewparser.Response{Findings: []reviewparser.Finding{
+			{StartLine: 3, Severity: "critical", Message: "nil map"},
+			{StartLine: 7, Severity: "minor", Message: "naming"},
+		}},
+	}
+
+	c.collectBlocking(job{reviewer: fakeReviewer{name: "docs"}}, analysis)
+	if len(c.blocking) != 0 {
+		t.Errorf("got %d blocking findings of a documentation definition", len(c.blocking))
+	}
+
+	c.collectBlocking(job{reviewer: fakeReviewer{name: "review", commentsOnPR: true}}, analysis)
+	if len(c.blocking) != 1 || c.blocking[0].definition != "review" || c.blocking[0].finding.File != "main.go" {
+		t.Errorf("got blocking findings %+v, want the critical one of the review", c.blocking)
+	}
+}
@@ -1,3 +1,11 @@
 #!/bin/sh
 
+if [ "${QREVIEW_SERVE}" = "true" ]; then
+  exec /usr/local/bin/qreview serve
+fi
+
+if [ "${QREVIEW_REPLY}" = "true" ]; then
+  exec /usr/local/bin/qreview -githubPr="${PR_URL}" -reply -comment
+fi
+
 exec /usr/local/bin/qreview -githubPr="${PR_URL}" -comment
@@ -0,0 +1,195 @@
+// Package cache stores AI responses on disk, content addressed by the hash of the request
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileExt = ".cache"
+
+// Cache implement this interface for response caches
+type Cache interface {
+	Get(key string) (string, bool)
+	Set(key, value string) error
+}
+
+// Key returns the hash of the parts, each part is length prefixed so parts cannot bleed into each other
+func Key(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(hash, "%d:%s;", len(part), part)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// NewDisk creates a cache in the given folder. Entries not used for ttl are ignored, when the folder
+// grows above maxSize bytes the least recently used entries are removed. Zero ttl or maxSize means no limit
+func NewDisk(dir string, ttl time.Duration, maxSize int64) Cache {
+	return &disk{
+		dir:     dir,
+		ttl:     ttl,
+		maxSize: maxSize,
+	}
+}
+
+type disk struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+	mu      sync.Mutex
+	size    int64 // Total size of the entries, known after the first walk of the folder
+	counted bool
+}
+
+// Get implements Cache. A hit touches the entry, so the modification time is the time of the last use
+func (d *disk) Get(key string) (string, bool) {
+	fileName := d.fileName(key)
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return "", false
+	}
+
+	if d.ttl > 0 && time.Since(info.ModTime()) > d.ttl {
+		os.Remove(fileName)
+		return "", false
+	}
+
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return "", false
+	}
+
+	now := time.Now()
+	os.Chtimes(fileName, now, now)
+
+	return string(content), true
+}
+
+// Set implements Cache.
+func (d *disk) Set(key, value string) error {
+	fileName := d.fileName(key)
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return fmt.Errorf("could not create cache folder: %w", err)
+	}
+
+	var replaced int64
+	if info, err := os.Stat(fileName); err == nil {
+		replaced = info.Size()
+	}
+
+	// Write to a temporary file first, so a concurrent Get never reads a half written entry
+	tmpFile, err := os.CreateTemp(filepath.Dir(fileName), "tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not create cache file: %w", err)
+	}
+
+	if _, err := tmpFile.WriteString(value); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("could not write cache file: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("could not write cache file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), fileName); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("could not save cache file: %w", err)
+	}
+
+	return d.evict(int64(len(value)) - replaced)
+}
+
+// fileName returns the path of the entry, fanned out into sub folders by the first two characters
+func (d *disk) fileName(key string) string {
+	return filepath.Join(d.dir, key[:2], key+fileExt)
+}
+
+type entry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// evict keeps the total size up to date with the grown bytes of a Set. The folder is only walked on the first call
+// and when the cache outgrows maxSize, then the expired entries are removed, and the least recently used ones
+// until the cache is 10% below maxSize, so the next entries fit without another walk
+func (d *disk) evict(grown int64) error {
+	if d.maxSize <= 0 && d.ttl <= 0 {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.counted {
+		d.size += grown
+		if d.maxSize <= 0 || d.size <= d.maxSize {
+			return nil
+		}
+	}
+
+	var entries []entry
+	var totalSize int64
+	err := filepath.WalkDir(d.dir, func(path string, dirEntry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if dirEntry.IsDir() || !strings.HasSuffix(path, fileExt) {
+			return nil
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			// removed by another process in the meantime
+			return nil
+		}
+
+		if d.ttl > 0 && time.Since(info.ModTime()) > d.ttl {
+			os.Remove(path)
+			return nil
+		}
+
+		entries = append(entries, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+		totalSize += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not read cache folder: %w", err)
+	}
+
+	d.size, d.counted = totalSize, true
+	if d.maxSize <= 0 || totalSize <= d.maxSize {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	lowWater := d.maxSize - d.maxSize/10
+	for _, e := range entries {
+		if totalSize <= lowWater {
+			break
+		}
+
+		if err := os.Remove(e.path); err == nil {
+			totalSize -= e.size
+		}
+	}
+	d.size = totalSize
+
+	return nil
+}
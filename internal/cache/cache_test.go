@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// age sets the time of the last use of the entry
+func age(t *testing.T, d *disk, key string, ago time.Duration) {
+	t.Helper()
+
+	at := time.Now().Add(-ago)
+	if err := os.Chtimes(d.fileName(key), at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskEvictsTheLeastRecentlyUsed(t *testing.T) {
+	d := NewDisk(t.TempDir(), 0, 250).(*disk)
+	value := strings.Repeat("x", 100)
+	a, b, c := Key("a"), Key("b"), Key("c")
+
+	for _, key := range []string{a, b} {
+		if err := d.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a is older, but read after b was written
+	age(t, d, a, 2*time.Hour)
+	age(t, d, b, time.Hour)
+	if _, ok := d.Get(a); !ok {
+		t.Fatal("got a miss on a")
+	}
+
+	if err := d.Set(c, value); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := d.Get(b); ok {
+		t.Error("got a hit on b, want the least recently used entry evicted")
+	}
+
+	for _, key := range []string{a, c} {
+		if _, ok := d.Get(key); !ok {
+			t.Errorf("got a miss on %s", key[:8])
+		}
+	}
+
+	if d.size != 200 {
+		t.Errorf("got size %d, want 200", d.size)
+	}
+}
+
+func TestDiskCountsReplacedEntriesOnce(t *testing.T) {
+	d := NewDisk(t.TempDir(), 0, 1000).(*disk)
+	for _, value := range []string{"first answer", "second"} {
+		if err := d.Set(Key("a"), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if d.size != int64(len("second")) {
+		t.Errorf("got size %d, want the size of the last value", d.size)
+	}
+}
+
+func TestDiskExpiresUnusedEntries(t *testing.T) {
+	d := NewDisk(t.TempDir(), time.Hour, 0).(*disk)
+	if err := d.Set(Key("a"), "answer"); err != nil {
+		t.Fatal(err)
+	}
+
+	age(t, d, Key("a"), 50*time.Minute)
+	if _, ok := d.Get(Key("a")); !ok {
+		t.Fatal("got a miss within the ttl")
+	}
+
+	// The hit above renewed it
+	if info, err := os.Stat(d.fileName(Key("a"))); err != nil || time.Since(info.ModTime()) > time.Minute {
+		t.Errorf("got %v, want the entry touched by the hit", err)
+	}
+
+	age(t, d, Key("a"), 2*time.Hour)
+	if _, ok := d.Get(Key("a")); ok {
+		t.Error("got a hit on an expired entry")
+	}
+}
@@ -7,10 +7,23 @@ import (
 )
 
 const (
-	FlagGithubPR = "githubpr" // GitHub PR have to be processed, follower by PR URL
-	FlagComment  = "comment"  // Also comment on the PR, if not set then it will be a screen/report only review
+	FlagGithubPR    = "githubpr"          // GitHub PR have to be processed, follower by PR URL
+	FlagGitlabMR    = "gitlabmr"          // GitLab MR have to be processed, followed by MR URL
+	FlagPR          = "pr"                // PR of any supported forge have to be processed, followed by PR URL
+	FlagComment     = "comment"           // Also comment on the PR, if not set then it will be a screen/report only review
+	FlagConcurrency = "concurrency"       // Number of reviews running in parallel, followed by a number, overrides CONCURRENCY
+	FlagContinue    = "continue-on-error" // Review the remaining files when one fails, and summarize the failures at the end
+	FlagNoCache     = "no-cache"          // Always call the AI, do not use or store cached responses
+	FlagFailOn      = "fail-on"           // Exit non zero if there are findings of this severity or above, overrides FAIL_ON
+	FlagMinimize    = "minimize-outdated" // Hide the PR comments of earlier runs whose finding is gone
+	FlagReply       = "reply"             // Answer the PR review threads mentioning qreview instead of reviewing
+	FlagCheck       = "check"             // Publish the result as a check run on the head commit of the PR
+	FlagReportDir   = "report-dir"        // Folder of the reports, followed by a path, report/<date>/<time> if not set
 )
 
+// CommandServe starts the webhook server instead of a single review, it is the first argument
+const CommandServe = "serve"
+
 func Arg(index int) (string, error) {
 	if index < 0 {
 		return "", fmt.Errorf("index cannot be negative")
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type ChangedLines []ChangedLine
@@ -13,9 +14,13 @@ type ChangedLines []ChangedLine
 type ChangedLine struct {
 	LineNum int
 	Content string
+	Hunk    int // Index of the hunk of the line, a range comment can not span hunks
 }
 
-var latestChanges ChangedLines
+var (
+	latestChanges ChangedLines
+	latestMu      sync.RWMutex
+)
 
 func GetMap(diff string) ChangedLines {
 	hunkStarted := false
@@ -23,12 +28,14 @@ func GetMap(diff string) ChangedLines {
 	scanner := bufio.NewScanner(strings.NewReader(diff))
 
 	var newLineNum int
+	hunk := -1
 
 	for scanner.Scan() {
 		line := scanner.Text()
 
 		if strings.HasPrefix(line, "@@") {
 			hunkStarted = true
+			hunk++
 			// Parse hunk header: @@ -a,b +c,d @@
 			parts := strings.Split(line, " ")
 			newHunk := parts[2] // "+c,d"
@@ -46,6 +53,7 @@ func GetMap(diff string) ChangedLines {
 			changes = append(changes, ChangedLine{
 				LineNum: newLineNum,
 				Content: line[1:], // strip "+"
+				Hunk:    hunk,
 			})
 			newLineNum++
 
@@ -56,25 +64,68 @@ func GetMap(diff string) ChangedLines {
 			changes = append(changes, ChangedLine{
 				LineNum: newLineNum,
 				Content: line[1:], // strip " "
+				Hunk:    hunk,
 			})
 			newLineNum++
 		}
 	}
 
+	latestMu.Lock()
 	latestChanges = changes
+	latestMu.Unlock()
+
 	return changes
 }
 
+// GetClosestPrOffset uses the map of the latest GetMap call, when maps are built concurrently
+// use ChangedLines.ClosestPrOffset on the map of the file instead
 func GetClosestPrOffset(prLineNr int) (int, error) {
+	latestMu.RLock()
+	defer latestMu.RUnlock()
+
 	if latestChanges == nil {
 		return 0, fmt.Errorf("you must run GetMap before getting the closest pr offset")
 	}
 
-	for i := len(latestChanges) - 1; i >= 0; i-- {
-		if latestChanges[i].LineNum <= prLineNr {
-			return latestChanges[i].LineNum, nil
+	return latestChanges.ClosestPrOffset(prLineNr)
+}
+
+// ClosestPrOffset returns the closest changed line at or before the given line, which can be commented on the PR
+func (c ChangedLines) ClosestPrOffset(prLineNr int) (int, error) {
+	if len(c) == 0 {
+		return 0, fmt.Errorf("there are no changed lines in the diff")
+	}
+
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].LineNum <= prLineNr {
+			return c[i].LineNum, nil
+		}
+	}
+
+	return c[0].LineNum, nil
+}
+
+// ClosestPrRange maps both ends of the line range to lines which can be commented on the PR. The end is the closest
+// line at or before it, the start is the first line of the range in the same hunk, or the end if there is none
+func (c ChangedLines) ClosestPrRange(startLineNr, endLineNr int) (int, int, error) {
+	end, err := c.ClosestPrOffset(endLineNr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var hunk int
+	for _, changed := range c {
+		if changed.LineNum == end {
+			hunk = changed.Hunk
+			break
+		}
+	}
+
+	for _, changed := range c {
+		if changed.Hunk == hunk && changed.LineNum >= startLineNr && changed.LineNum <= end {
+			return changed.LineNum, end, nil
 		}
 	}
 
-	return latestChanges[0].LineNum, nil
+	return end, end, nil
 }
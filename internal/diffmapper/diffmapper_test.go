@@ -0,0 +1,53 @@
+package diffmapper
+
+import "testing"
+
+// testDiff has two hunks, the lines 1 to 4 and 21 to 23 of the new file
+const testDiff = "diff --git a/main.go b/main.go\n" +
+	"--- a/main.go\n" +
+	"+++ b/main.go\n" +
+	"@@ -1,3 +1,4 @@\n" +
+	" package main\n" +
+	"+import \"fmt\"\n" +
+	" \n" +
+	" func main() {\n" +
+	"@@ -20,3 +21,3 @@\n" +
+	" \tx := 1\n" +
+	"-\tfmt.Println(y)\n" +
+	"+\tfmt.Println(x)\n" +
+	" }\n"
+
+func TestClosestPrRange(t *testing.T) {
+	changes := GetMap(testDiff)
+
+	tests := []struct {
+		name      string
+		start     int
+		end       int
+		wantStart int
+		wantEnd   int
+	}{
+		{"inside a hunk", 2, 3, 2, 3},
+		{"single line", 22, 22, 22, 22},
+		{"spanning two hunks", 3, 22, 21, 22},
+		{"ending between the hunks", 3, 10, 3, 4},
+		{"between the hunks", 10, 15, 4, 4},
+		{"after the diff", 30, 35, 23, 23},
+		{"before the diff", 0, 0, 1, 1},
+	}
+
+	for _, test := range tests {
+		start, end, err := changes.ClosestPrRange(test.start, test.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if start != test.wantStart || end != test.wantEnd {
+			t.Errorf("%s: got %d-%d for %d-%d, want %d-%d", test.name, start, end, test.start, test.end, test.wantStart, test.wantEnd)
+		}
+	}
+
+	if _, _, err := ChangedLines(nil).ClosestPrRange(1, 2); err == nil {
+		t.Error("got no error without changed lines")
+	}
+}
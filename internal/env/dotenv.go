@@ -14,11 +14,58 @@ const (
 	EnvAIClient           = "AI_CLIENT"
 	EnvFileExtensions     = "FILE_EXTENSIONS"
 	EnvGithubToken        = "GITHUB_TOKEN"
+	EnvGithubAPIURL       = "GITHUB_API_URL"
+	EnvGitlabToken        = "GITLAB_TOKEN"
+	EnvGitlabAPIURL       = "GITLAB_API_URL"
+	EnvPRProvider         = "PR_PROVIDER"
+	EnvBitbucketToken     = "BITBUCKET_TOKEN"
+	EnvBitbucketUsername  = "BITBUCKET_USERNAME"
+	EnvBitbucketAPIURL    = "BITBUCKET_API_URL"
+	EnvGiteaToken         = "GITEA_TOKEN"
+	EnvGiteaAPIURL        = "GITEA_API_URL"
+	EnvForgeTimeout       = "FORGE_TIMEOUT"
 	EnvAwsAccessKeyID     = "AWS_ACCESS_KEY_ID"
 	EnvAwsSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
 	EnvAwsRegion          = "AWS_REGION"
 	EnvQReviewAPIEndpoint = "QREVIEW_API_ENDPOINT"
 	EnvContextLines       = "CONTEXT_LINES"
+	EnvOllamaHost         = "OLLAMA_HOST"
+	EnvOllamaModel        = "OLLAMA_MODEL"
+	EnvOllamaTemperature  = "OLLAMA_TEMPERATURE"
+	EnvOllamaNumCtx       = "OLLAMA_NUM_CTX"
+	EnvOllamaTimeout      = "OLLAMA_TIMEOUT"
+	EnvOpenAIBaseURL      = "OPENAI_BASE_URL"
+	EnvOpenAIAPIKey       = "OPENAI_API_KEY"
+	EnvOpenAIModel        = "OPENAI_MODEL"
+	EnvOpenAITimeout      = "OPENAI_TIMEOUT"
+	EnvBedrockModelID     = "BEDROCK_MODEL_ID"
+	EnvBedrockAPI         = "BEDROCK_API"
+	EnvBedrockMaxTokens   = "BEDROCK_MAX_TOKENS"
+	EnvBedrockTemperature = "BEDROCK_TEMPERATURE"
+	EnvBedrockSystem      = "BEDROCK_SYSTEM_PROMPT"
+	EnvBedrockTimeout     = "BEDROCK_TIMEOUT"
+	EnvBedrockContext     = "BEDROCK_CONTEXT_WINDOW"
+	EnvConcurrency        = "CONCURRENCY"
+	EnvMaxInputTokens     = "MAX_INPUT_TOKENS"
+	EnvMaxChunks          = "MAX_CHUNKS"
+	EnvContinueOnError    = "CONTINUE_ON_ERROR"
+	EnvFailOn             = "FAIL_ON"
+	EnvMinimizeOutdated   = "MINIMIZE_OUTDATED_COMMENTS"
+	EnvGithubCheck        = "GITHUB_CHECK"
+	EnvReplyMention       = "REPLY_MENTION"
+	EnvServeAddr          = "SERVE_ADDR"
+	EnvServeConcurrency   = "SERVE_CONCURRENCY"
+	EnvServeQueueSize     = "SERVE_QUEUE_SIZE"
+	EnvWebhookSecret      = "WEBHOOK_SECRET"
+	EnvNoCache            = "NO_CACHE"
+	EnvCacheDir           = "CACHE_DIR"
+	EnvCacheTTL           = "CACHE_TTL"
+	EnvCacheMaxSize       = "CACHE_MAX_SIZE"
+	EnvAIMaxAttempts      = "AI_MAX_ATTEMPTS"
+	EnvAIRetryBaseDelay   = "AI_RETRY_BASE_DELAY_MS"
+	EnvAIRetryMaxDelay    = "AI_RETRY_MAX_DELAY_MS"
+	EnvAIRequestsPerMin   = "AI_REQUESTS_PER_MINUTE"
+	EnvAITokensPerMin     = "AI_TOKENS_PER_MINUTE"
 )
 
 // NewDotEnv creates a new environment manager that loads from .env file
@@ -50,6 +97,57 @@ func (e *dotenv) GithubToken() string {
 	return os.Getenv(EnvGithubToken)
 }
 
+// GithubAPIURL returns the URL of the GitHub REST API, empty means the API of the host of the PR URL
+func (e *dotenv) GithubAPIURL() string {
+	return strings.TrimSuffix(os.Getenv(EnvGithubAPIURL), "/")
+}
+
+// GitlabToken returns the GitLab token
+func (e *dotenv) GitlabToken() string {
+	return os.Getenv(EnvGitlabToken)
+}
+
+// GitlabAPIURL returns the URL of the GitLab v4 API, empty means the API of the host of the MR URL
+func (e *dotenv) GitlabAPIURL() string {
+	return strings.TrimSuffix(os.Getenv(EnvGitlabAPIURL), "/")
+}
+
+// PRProvider returns the forge of the PR URL, github, gitlab, bitbucket, bitbucket-server or gitea,
+// empty means it is detected by the URL pattern
+func (e *dotenv) PRProvider() string {
+	return strings.ToLower(strings.TrimSpace(os.Getenv(EnvPRProvider)))
+}
+
+// BitbucketToken returns the Bitbucket access token, or the app password if BitbucketUsername is set
+func (e *dotenv) BitbucketToken() string {
+	return os.Getenv(EnvBitbucketToken)
+}
+
+// BitbucketUsername returns the user of the Bitbucket app password, empty means the token is a bearer token
+func (e *dotenv) BitbucketUsername() string {
+	return os.Getenv(EnvBitbucketUsername)
+}
+
+// BitbucketAPIURL returns the URL of the Bitbucket API, empty means the API of Bitbucket Cloud or of the Bitbucket Server host
+func (e *dotenv) BitbucketAPIURL() string {
+	return strings.TrimSuffix(os.Getenv(EnvBitbucketAPIURL), "/")
+}
+
+// GiteaToken returns the Gitea or Forgejo token
+func (e *dotenv) GiteaToken() string {
+	return os.Getenv(EnvGiteaToken)
+}
+
+// GiteaAPIURL returns the URL of the Gitea or Forgejo API, empty means the API of the host of the PR URL
+func (e *dotenv) GiteaAPIURL() string {
+	return strings.TrimSuffix(os.Getenv(EnvGiteaAPIURL), "/")
+}
+
+// ForgeTimeout returns the timeout of a forge API request in seconds
+func (e *dotenv) ForgeTimeout() int {
+	return getEnvAsInt(EnvForgeTimeout, 60)
+}
+
 // AwsAccessKeyID returns the AWS access key ID
 func (e *dotenv) AwsAccessKeyID() string {
 	return os.Getenv(EnvAwsAccessKeyID)
@@ -83,6 +181,250 @@ func (e *dotenv) ContextLines() int {
 	return getEnvAsInt(EnvContextLines, 5)
 }
 
+// OllamaHost returns the base URL of the Ollama REST API
+func (e *dotenv) OllamaHost() string {
+	host := os.Getenv(EnvOllamaHost)
+	if host == "" {
+		return "http://localhost:11434"
+	}
+
+	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		host = "http://" + host
+	}
+
+	return strings.TrimSuffix(host, "/")
+}
+
+// OllamaModel returns the Ollama model name used for the review
+func (e *dotenv) OllamaModel() string {
+	model := os.Getenv(EnvOllamaModel)
+	if model == "" {
+		return "llama3"
+	}
+	return model
+}
+
+// OllamaTemperature returns the sampling temperature sent to Ollama
+func (e *dotenv) OllamaTemperature() float64 {
+	return getEnvAsFloat(EnvOllamaTemperature, 0.2)
+}
+
+// OllamaNumCtx returns the context window size, 0 means the model default
+func (e *dotenv) OllamaNumCtx() int {
+	return getEnvAsInt(EnvOllamaNumCtx, 0)
+}
+
+// OllamaTimeout returns the Ollama request timeout in seconds
+func (e *dotenv) OllamaTimeout() int {
+	return getEnvAsInt(EnvOllamaTimeout, 300)
+}
+
+// OpenAIBaseURL returns the base URL of an OpenAI compatible API, including the /v1 part
+func (e *dotenv) OpenAIBaseURL() string {
+	baseURL := os.Getenv(EnvOpenAIBaseURL)
+	if baseURL == "" {
+		return "https://api.openai.com/v1"
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// OpenAIAPIKey returns the API key sent as bearer token, may be empty for local gateways
+func (e *dotenv) OpenAIAPIKey() string {
+	return os.Getenv(EnvOpenAIAPIKey)
+}
+
+// OpenAIModel returns the model name sent to the chat completions endpoint
+func (e *dotenv) OpenAIModel() string {
+	model := os.Getenv(EnvOpenAIModel)
+	if model == "" {
+		return "gpt-4o-mini"
+	}
+	return model
+}
+
+// OpenAITimeout returns the chat completions request timeout in seconds
+func (e *dotenv) OpenAITimeout() int {
+	return getEnvAsInt(EnvOpenAITimeout, 300)
+}
+
+// BedrockModelID returns the Bedrock model ID
+func (e *dotenv) BedrockModelID() string {
+	modelID := os.Getenv(EnvBedrockModelID)
+	if modelID == "" {
+		return "anthropic.claude-v2"
+	}
+	return modelID
+}
+
+// BedrockAPI returns which Bedrock API is used: converse, invoke or empty to decide by the model ID
+func (e *dotenv) BedrockAPI() string {
+	return strings.ToLower(os.Getenv(EnvBedrockAPI))
+}
+
+// BedrockMaxTokens returns the maximum number of tokens the model may generate
+func (e *dotenv) BedrockMaxTokens() int {
+	return getEnvAsInt(EnvBedrockMaxTokens, 4096)
+}
+
+// BedrockTemperature returns the sampling temperature, and false if it is not set
+func (e *dotenv) BedrockTemperature() (float64, bool) {
+	if os.Getenv(EnvBedrockTemperature) == "" {
+		return 0, false
+	}
+
+	return getEnvAsFloat(EnvBedrockTemperature, 0), true
+}
+
+// BedrockSystemPrompt returns the system prompt sent to Bedrock, may be empty
+func (e *dotenv) BedrockSystemPrompt() string {
+	return os.Getenv(EnvBedrockSystem)
+}
+
+// BedrockTimeout returns the Bedrock request timeout in seconds
+func (e *dotenv) BedrockTimeout() int {
+	return getEnvAsInt(EnvBedrockTimeout, 60)
+}
+
+// BedrockContextWindow returns the context window of the Bedrock model in tokens, 0 derives it from the model ID
+func (e *dotenv) BedrockContextWindow() int {
+	return getEnvAsInt(EnvBedrockContext, 0)
+}
+
+// Concurrency returns how many reviews may run in parallel
+func (e *dotenv) Concurrency() int {
+	concurrency := getEnvAsInt(EnvConcurrency, 1)
+	if concurrency < 1 {
+		return 1
+	}
+	return concurrency
+}
+
+// MaxInputTokens overrides the context budget of the backend, 0 means the backend default
+func (e *dotenv) MaxInputTokens() int {
+	return getEnvAsInt(EnvMaxInputTokens, 0)
+}
+
+// MaxChunks returns into how many chunks a large file may be split, larger files are skipped
+func (e *dotenv) MaxChunks() int {
+	return getEnvAsInt(EnvMaxChunks, 10)
+}
+
+// ContinueOnError tells if the review goes on when a file fails, failures are summarized at the end
+func (e *dotenv) ContinueOnError() bool {
+	return getEnvAsBool(EnvContinueOnError, false)
+}
+
+// FailOn returns the severity from which findings make the review fail, empty means never
+func (e *dotenv) FailOn() string {
+	return strings.ToLower(strings.TrimSpace(os.Getenv(EnvFailOn)))
+}
+
+// MinimizeOutdatedComments tells if the PR comments of earlier runs, whose finding is gone, are hidden as outdated
+func (e *dotenv) MinimizeOutdatedComments() bool {
+	return getEnvAsBool(EnvMinimizeOutdated, false)
+}
+
+// GithubCheck tells if the result of a PR review is published as a check run on the head commit
+func (e *dotenv) GithubCheck() bool {
+	return getEnvAsBool(EnvGithubCheck, false)
+}
+
+// ReplyMention returns the mention which asks qreview to answer in a review thread
+func (e *dotenv) ReplyMention() string {
+	mention := strings.TrimSpace(os.Getenv(EnvReplyMention))
+	if mention == "" {
+		return "@qreview"
+	}
+
+	return mention
+}
+
+// ServeAddr returns the address the webhook server listens on
+func (e *dotenv) ServeAddr() string {
+	addr := strings.TrimSpace(os.Getenv(EnvServeAddr))
+	if addr == "" {
+		return ":8080"
+	}
+
+	return addr
+}
+
+// ServeConcurrency returns the number of jobs the webhook server runs at the same time
+func (e *dotenv) ServeConcurrency() int {
+	concurrency := getEnvAsInt(EnvServeConcurrency, 2)
+	if concurrency < 1 {
+		return 1
+	}
+	return concurrency
+}
+
+// ServeQueueSize returns the number of jobs the webhook server keeps waiting, further events are rejected
+func (e *dotenv) ServeQueueSize() int {
+	size := getEnvAsInt(EnvServeQueueSize, 100)
+	if size < 1 {
+		return 1
+	}
+	return size
+}
+
+// WebhookSecret returns the secret of the webhooks, GitHub signs the payloads with it, GitLab sends it as the token
+func (e *dotenv) WebhookSecret() string {
+	return os.Getenv(EnvWebhookSecret)
+}
+
+// NoCache tells if the AI response cache is disabled
+func (e *dotenv) NoCache() bool {
+	return getEnvAsBool(EnvNoCache, false)
+}
+
+// CacheDir returns the folder of the AI response cache
+func (e *dotenv) CacheDir() string {
+	dir := os.Getenv(EnvCacheDir)
+	if dir == "" {
+		return ".qreview-cache"
+	}
+	return dir
+}
+
+// CacheTTL returns how long a cached AI response is valid in hours, 0 means forever
+func (e *dotenv) CacheTTL() int {
+	return getEnvAsInt(EnvCacheTTL, 168)
+}
+
+// CacheMaxSize returns the maximum size of the AI response cache in megabytes, 0 means unlimited
+func (e *dotenv) CacheMaxSize() int {
+	return getEnvAsInt(EnvCacheMaxSize, 100)
+}
+
+// AIMaxAttempts returns how many times a model call is attempted before giving up
+func (e *dotenv) AIMaxAttempts() int {
+	attempts := getEnvAsInt(EnvAIMaxAttempts, 3)
+	if attempts < 1 {
+		return 1
+	}
+	return attempts
+}
+
+// AIRetryBaseDelay returns the delay before the first retry in milliseconds, it doubles on each retry
+func (e *dotenv) AIRetryBaseDelay() int {
+	return getEnvAsInt(EnvAIRetryBaseDelay, 2000)
+}
+
+// AIRetryMaxDelay returns the maximum delay between retries in milliseconds
+func (e *dotenv) AIRetryMaxDelay() int {
+	return getEnvAsInt(EnvAIRetryMaxDelay, 60000)
+}
+
+// AIRequestsPerMinute returns the client side request limit for model calls, 0 means unlimited
+func (e *dotenv) AIRequestsPerMinute() int {
+	return getEnvAsInt(EnvAIRequestsPerMin, 0)
+}
+
+// AITokensPerMinute returns the client side estimated input token limit for model calls, 0 means unlimited
+func (e *dotenv) AITokensPerMinute() int {
+	return getEnvAsInt(EnvAITokensPerMin, 0)
+}
+
 // ShouldProcessFile checks if the file should be processed based on its extension
 func (e *dotenv) ShouldProcessFile(fileName string) bool {
 	extensions := e.FileExtensions()
@@ -121,3 +463,31 @@ func getEnvAsInt(key string, defaultVal int) int {
 
 	return intVal
 }
+
+func getEnvAsFloat(key string, defaultVal float64) float64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+
+	floatVal, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return defaultVal
+	}
+
+	return floatVal
+}
+
+func getEnvAsBool(key string, defaultVal bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+
+	boolVal, err := strconv.ParseBool(val)
+	if err != nil {
+		return defaultVal
+	}
+
+	return boolVal
+}
@@ -5,10 +5,57 @@ type EnvironmentManager interface {
 	Client() string
 	FileExtensions() []string
 	GithubToken() string
+	GithubAPIURL() string
+	GitlabToken() string
+	GitlabAPIURL() string
+	PRProvider() string
+	BitbucketToken() string
+	BitbucketUsername() string
+	BitbucketAPIURL() string
+	GiteaToken() string
+	GiteaAPIURL() string
+	ForgeTimeout() int
 	AwsAccessKeyID() string
 	AwsSecretAccessKey() string
 	AwsRegion() string
 	QReviewAPIEndpoint() string
 	ShouldProcessFile(fileName string) bool
 	ContextLines() int
-}
\ No newline at end of file
+	OllamaHost() string
+	OllamaModel() string
+	OllamaTemperature() float64
+	OllamaNumCtx() int
+	OllamaTimeout() int
+	OpenAIBaseURL() string
+	OpenAIAPIKey() string
+	OpenAIModel() string
+	OpenAITimeout() int
+	BedrockModelID() string
+	BedrockAPI() string
+	BedrockMaxTokens() int
+	BedrockTemperature() (float64, bool)
+	BedrockSystemPrompt() string
+	BedrockTimeout() int
+	BedrockContextWindow() int
+	Concurrency() int
+	MaxInputTokens() int
+	MaxChunks() int
+	ContinueOnError() bool
+	FailOn() string
+	MinimizeOutdatedComments() bool
+	GithubCheck() bool
+	ReplyMention() string
+	ServeAddr() string
+	ServeConcurrency() int
+	ServeQueueSize() int
+	WebhookSecret() string
+	NoCache() bool
+	CacheDir() string
+	CacheTTL() int
+	CacheMaxSize() int
+	AIMaxAttempts() int
+	AIRetryBaseDelay() int
+	AIRetryMaxDelay() int
+	AIRequestsPerMinute() int
+	AITokensPerMinute() int
+}
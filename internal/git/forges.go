@@ -0,0 +1,87 @@
+package git
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+var (
+	bitbucketCloudPRRegex  = regexp.MustCompile(`^https?://[^/]+/([^/]+)/([^/]+)/pull-requests/(\d+)(/[^/]*)?$`)
+	bitbucketServerPRRegex = regexp.MustCompile(`^https?://[^/]+(/.*)?/projects/([^/]+)/repos/([^/]+)/pull-requests/(\d+)(/[^/]*)?$`)
+	giteaPRRegex           = regexp.MustCompile(`^https?://[^/]+(/.*)?/([^/]+)/([^/]+)/pulls/(\d+)(/[^/]*)?$`)
+)
+
+// PRLocation is a pull request of a forge, Owner is the workspace, project key or owner of the repository
+type PRLocation struct {
+	HostURL string // Scheme, host and the path prefix the forge is served on
+	Owner   string
+	Repo    string
+	Number  int
+}
+
+// GetBitbucketCloudPRInfo phrases Bitbucket Cloud PR URL like https://bitbucket.org/workspace/repo/pull-requests/1
+func GetBitbucketCloudPRInfo(prURL string) (PRLocation, error) {
+	match := bitbucketCloudPRRegex.FindStringSubmatch(prURL)
+	if match == nil || IsValidBitbucketServerPRURL(prURL) {
+		return PRLocation{}, fmt.Errorf("the Bitbucket PR url is invalid: %s", prURL)
+	}
+
+	return prLocation(prURL, "", match[1], match[2], match[3])
+}
+
+// GetBitbucketServerPRInfo phrases Bitbucket Server / Data Center PR URL like
+// https://bitbucket.example.com/projects/KEY/repos/repo/pull-requests/1/overview
+func GetBitbucketServerPRInfo(prURL string) (PRLocation, error) {
+	match := bitbucketServerPRRegex.FindStringSubmatch(prURL)
+	if match == nil {
+		return PRLocation{}, fmt.Errorf("the Bitbucket Server PR url is invalid: %s", prURL)
+	}
+
+	return prLocation(prURL, match[1], match[2], match[3], match[4])
+}
+
+// GetGiteaPRInfo phrases Gitea or Forgejo PR URL like https://codeberg.org/owner/repo/pulls/1
+func GetGiteaPRInfo(prURL string) (PRLocation, error) {
+	match := giteaPRRegex.FindStringSubmatch(prURL)
+	if match == nil {
+		return PRLocation{}, fmt.Errorf("the Gitea PR url is invalid: %s", prURL)
+	}
+
+	return prLocation(prURL, match[1], match[2], match[3], match[4])
+}
+
+// IsValidBitbucketCloudPRURL checks whether the given URL is a valid Bitbucket Cloud Pull Request URL
+func IsValidBitbucketCloudPRURL(prURL string) bool {
+	return bitbucketCloudPRRegex.MatchString(prURL) && !IsValidBitbucketServerPRURL(prURL)
+}
+
+// IsValidBitbucketServerPRURL checks whether the given URL is a valid Bitbucket Server Pull Request URL
+func IsValidBitbucketServerPRURL(prURL string) bool {
+	return bitbucketServerPRRegex.MatchString(prURL)
+}
+
+// IsValidGiteaPRURL checks whether the given URL is a valid Gitea or Forgejo Pull Request URL
+func IsValidGiteaPRURL(prURL string) bool {
+	return giteaPRRegex.MatchString(prURL)
+}
+
+func prLocation(prURL, pathPrefix, owner, repo, number string) (PRLocation, error) {
+	u, err := url.Parse(prURL)
+	if err != nil {
+		return PRLocation{}, fmt.Errorf("the PR url is invalid: %s, %w", prURL, err)
+	}
+
+	prNumber, err := strconv.Atoi(number)
+	if err != nil {
+		return PRLocation{}, fmt.Errorf("not a valid PR URL, PR number is not a number %s", prURL)
+	}
+
+	return PRLocation{
+		HostURL: u.Scheme + "://" + u.Host + pathPrefix,
+		Owner:   owner,
+		Repo:    repo,
+		Number:  prNumber,
+	}, nil
+}
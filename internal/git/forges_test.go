@@ -0,0 +1,72 @@
+package git
+
+import "testing"
+
+func TestForgePRLocations(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) (PRLocation, error)
+		prURL string
+		want  PRLocation
+	}{
+		{"bitbucket cloud", GetBitbucketCloudPRInfo, "https://bitbucket.org/ws/repo/pull-requests/12",
+			PRLocation{HostURL: "https://bitbucket.org", Owner: "ws", Repo: "repo", Number: 12}},
+		{"bitbucket cloud tab", GetBitbucketCloudPRInfo, "https://bitbucket.org/ws/repo/pull-requests/12/diff",
+			PRLocation{HostURL: "https://bitbucket.org", Owner: "ws", Repo: "repo", Number: 12}},
+		{"bitbucket server", GetBitbucketServerPRInfo, "https://bitbucket.example.com/projects/KEY/repos/repo/pull-requests/3/overview",
+			PRLocation{HostURL: "https://bitbucket.example.com", Owner: "KEY", Repo: "repo", Number: 3}},
+		{"bitbucket server with context path", GetBitbucketServerPRInfo, "https://example.com/bitbucket/projects/KEY/repos/repo/pull-requests/3",
+			PRLocation{HostURL: "https://example.com/bitbucket", Owner: "KEY", Repo: "repo", Number: 3}},
+		{"gitea", GetGiteaPRInfo, "https://codeberg.org/owner/repo/pulls/7",
+			PRLocation{HostURL: "https://codeberg.org", Owner: "owner", Repo: "repo", Number: 7}},
+		{"gitea with sub path", GetGiteaPRInfo, "http://example.com/gitea/owner/repo/pulls/7/files",
+			PRLocation{HostURL: "http://example.com/gitea", Owner: "owner", Repo: "repo", Number: 7}},
+	}
+
+	for _, test := range tests {
+		got, err := test.parse(test.prURL)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestForgePRURLDetection(t *testing.T) {
+	tests := []struct {
+		prURL           string
+		bitbucketCloud  bool
+		bitbucketServer bool
+		gitea           bool
+	}{
+		{"https://bitbucket.org/ws/repo/pull-requests/1", true, false, false},
+		{"https://bitbucket.example.com/projects/KEY/repos/repo/pull-requests/1", false, true, false},
+		{"https://codeberg.org/owner/repo/pulls/1", false, false, true},
+		{"https://github.com/owner/repo/pull/1", false, false, false},
+		{"https://gitlab.com/group/project/-/merge_requests/1", false, false, false},
+		{"https://codeberg.org/owner/repo/pulls/abc", false, false, false},
+		{"not a url", false, false, false},
+	}
+
+	for _, test := range tests {
+		if got := IsValidBitbucketCloudPRURL(test.prURL); got != test.bitbucketCloud {
+			t.Errorf("got Bitbucket Cloud %t for %s", got, test.prURL)
+		}
+
+		if got := IsValidBitbucketServerPRURL(test.prURL); got != test.bitbucketServer {
+			t.Errorf("got Bitbucket Server %t for %s", got, test.prURL)
+		}
+
+		if got := IsValidGiteaPRURL(test.prURL); got != test.gitea {
+			t.Errorf("got Gitea %t for %s", got, test.prURL)
+		}
+	}
+
+	if _, err := GetBitbucketCloudPRInfo("https://bitbucket.example.com/projects/KEY/repos/repo/pull-requests/1"); err == nil {
+		t.Error("got a Bitbucket Cloud PR of a Bitbucket Server URL")
+	}
+}
@@ -0,0 +1,40 @@
+package git
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GetMRInfo phrases GitLab MR URL like https://gitlab.example.com/group/project/-/merge_requests/42:
+// returns the URL of the GitLab host, the project path, the MR IID, error
+func GetMRInfo(mrURL string) (string, string, int, error) {
+	if !IsValidGitLabMRURL(mrURL) {
+		return "", "", 0, fmt.Errorf("the MR url is invalid: %s", mrURL)
+	}
+
+	u, err := url.Parse(mrURL)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("the MR url is invalid: %s, %w", mrURL, err)
+	}
+
+	projectPath, mrPart, ok := strings.Cut(strings.Trim(u.Path, "/"), "/-/merge_requests/")
+	if !ok {
+		return "", "", 0, fmt.Errorf("not a valid MR URL %s", mrURL)
+	}
+
+	mrIID, err := strconv.Atoi(mrPart)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("not a valid MR URL, MR number is not a number %s", mrURL)
+	}
+
+	return u.Scheme + "://" + u.Host, projectPath, mrIID, nil
+}
+
+// IsValidGitLabMRURL checks whether the given URL is a valid GitLab Merge Request URL
+func IsValidGitLabMRURL(mrURL string) bool {
+	re := regexp.MustCompile(`^https?://[^/]+/[^/]+(/[^/]+)+/-/merge_requests/\d+$`)
+	return re.MatchString(mrURL)
+}
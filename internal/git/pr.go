@@ -8,6 +8,8 @@ import (
 	"strings"
 )
 
+var gitHubPRRegex = regexp.MustCompile(`^https?://[^/]+/[^/]+/[^/]+/pull/\d+$`)
+
 // GetPRInfo phrases GitHub PR URL: returns owner, repo, prNumber, error
 func GetPRInfo(prURL string) (string, string, int, error) {
 	if !IsValidGitHubPRURL(prURL) {
@@ -34,8 +36,21 @@ func GetPRInfo(prURL string) (string, string, int, error) {
 	return owner, repo, prNumber, nil
 }
 
-// IsValidGitHubPRURL checks whether the given URL is a valid GitHub Pull Request URL
+// GetPRHostURL returns the scheme and host of the GitHub PR URL, like https://github.example.com for GitHub Enterprise Server
+func GetPRHostURL(prURL string) (string, error) {
+	if !IsValidGitHubPRURL(prURL) {
+		return "", fmt.Errorf("the PR url is invalid: %s", prURL)
+	}
+
+	u, err := url.Parse(prURL)
+	if err != nil {
+		return "", fmt.Errorf("the PR url is invalid: %s, %w", prURL, err)
+	}
+
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// IsValidGitHubPRURL checks whether the given URL is a valid GitHub Pull Request URL, on github.com or on a GitHub Enterprise Server host
 func IsValidGitHubPRURL(prURL string) bool {
-	re := regexp.MustCompile(`^https?://github\.com/[^/]+/[^/]+/pull/\d+$`)
-	return re.MatchString(prURL)
+	return gitHubPRRegex.MatchString(prURL)
 }
@@ -99,11 +99,12 @@ func save(fileName string, links []string) error {
 		return err
 	}
 
-	file, err := os.Create(fileName)
+	// The index is written next to its place and renamed, parallel runs of the webhook server share the parent indexes
+	file, err := os.CreateTemp(filepath.Dir(fileName), ".index-*.html")
 	if err != nil {
-
 		return err
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
 	items := make([]Item, len(links))
@@ -123,5 +124,13 @@ func save(fileName string, links []string) error {
 		return err
 	}
 
-	return nil
+	if err := file.Chmod(0644); err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), fileName)
 }
@@ -0,0 +1,192 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/git"
+)
+
+const bitbucketCloudAPIURL = "https://api.bitbucket.org/2.0"
+
+var (
+	bitbucketHeadCache  runCache[string]
+	bitbucketDiffsCache runCache[[]FileDiff]
+)
+
+// NewBitbucket creates a client of the Bitbucket Cloud 2.0 API. BITBUCKET_TOKEN is sent as a bearer token,
+// or as an app password if BITBUCKET_USERNAME is set
+func NewBitbucket(env env.EnvironmentManager) *Bitbucket {
+	return &Bitbucket{
+		env: env,
+		client: newRESTClient("Bitbucket", time.Duration(env.ForgeTimeout())*time.Second, func(req *http.Request) {
+			if username := env.BitbucketUsername(); username != "" {
+				req.SetBasicAuth(username, env.BitbucketToken())
+				return
+			}
+
+			req.Header.Set("Authorization", "Bearer "+env.BitbucketToken())
+		}),
+	}
+}
+
+// Bitbucket is the Bitbucket Cloud pull request client, it implements PullRequest
+type Bitbucket struct {
+	env    env.EnvironmentManager
+	client restClient
+}
+
+// GetPRFiles implements PullRequest.
+func (b *Bitbucket) GetPRFiles(prURL string) ([]string, error) {
+	base, err := b.base(prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	type diffStatPage struct {
+		Values []struct {
+			Status string `json:"status"`
+			New    *struct {
+				Path string `json:"path"`
+			} `json:"new"`
+		} `json:"values"`
+		Next string `json:"next"`
+	}
+
+	var fileNames []string
+	for next := base + "/diffstat"; next != ""; {
+		var page diffStatPage
+		if _, err := b.client.getJSON(next, &page); err != nil {
+			return nil, err
+		}
+
+		for _, value := range page.Values {
+			if value.Status == "removed" || value.New == nil {
+				continue // skip deleted files
+			}
+
+			fileNames = append(fileNames, value.New.Path)
+		}
+
+		next = page.Next
+	}
+
+	return fileNames, nil
+}
+
+// GetPRFileContent implements PullRequest, the file is read at the head commit of the pull request
+func (b *Bitbucket) GetPRFileContent(prURL, filePath string) (string, error) {
+	headSHA, err := b.HeadSHA(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	repoURL, _, err := b.repository(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	content, _, err := b.client.do(context.Background(), http.MethodGet, fmt.Sprintf("%s/src/%s/%s", repoURL, headSHA, escapePath(filePath)), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// GetPRFileDiffs implements PullRequest.
+func (b *Bitbucket) GetPRFileDiffs(prURL string) ([]FileDiff, error) {
+	return bitbucketDiffsCache.get(prURL, func() ([]FileDiff, error) {
+		base, err := b.base(prURL)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, _, err := b.client.do(context.Background(), http.MethodGet, base+"/diff", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return splitDiff(string(raw)), nil
+	})
+}
+
+// HeadSHA returns the commit of the source branch of the pull request, it is fetched once per run
+func (b *Bitbucket) HeadSHA(prURL string) (string, error) {
+	return bitbucketHeadCache.get(prURL, func() (string, error) {
+		base, err := b.base(prURL)
+		if err != nil {
+			return "", err
+		}
+
+		var result struct {
+			Source struct {
+				Commit struct {
+					Hash string `json:"hash"`
+				} `json:"commit"`
+			} `json:"source"`
+		}
+
+		if _, err := b.client.getJSON(base, &result); err != nil {
+			return "", err
+		}
+
+		return result.Source.Commit.Hash, nil
+	})
+}
+
+// CreateComment posts an inline comment on a line of the new version of the file
+func (b *Bitbucket) CreateComment(prURL, filePath string, line int, body string) error {
+	base, err := b.base(prURL)
+	if err != nil {
+		return err
+	}
+
+	return b.client.postJSON(base+"/comments", map[string]interface{}{
+		"content": map[string]string{"raw": body},
+		"inline": map[string]interface{}{
+			"path": filePath,
+			"to":   line,
+		},
+	})
+}
+
+// repository returns the API URL of the repository and the PR number of the PR URL
+func (b *Bitbucket) repository(prURL string) (string, int, error) {
+	location, err := git.GetBitbucketCloudPRInfo(prURL)
+	if err != nil {
+		return "", 0, err
+	}
+
+	apiURL := b.env.BitbucketAPIURL()
+	if apiURL == "" {
+		apiURL = bitbucketCloudAPIURL
+	}
+
+	return fmt.Sprintf("%s/repositories/%s/%s", apiURL, location.Owner, location.Repo), location.Number, nil
+}
+
+// base returns the API URL of the pull request
+func (b *Bitbucket) base(prURL string) (string, error) {
+	repoURL, prNumber, err := b.repository(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/pullrequests/%d", repoURL, prNumber), nil
+}
+
+// escapePath escapes the segments of the file path, keeping the slashes
+func escapePath(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
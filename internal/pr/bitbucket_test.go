@@ -0,0 +1,142 @@
+package pr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/env"
+)
+
+const bitbucketTestPR = "/repositories/ws/repo/pullrequests/5"
+
+// newTestBitbucket serves a pull request on an httptest server, and returns the client with the URL of the pull request.
+// The bodies of the created comments are sent to the comments channel
+func newTestBitbucket(t *testing.T, username string, comments chan<- map[string]any) (*Bitbucket, string) {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); username != "" && (!ok || user != username || password != "secret") {
+			t.Errorf("got basic auth %q, %q on %s", user, password, r.URL)
+		} else if username == "" && r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("got authorization %q on %s", r.Header.Get("Authorization"), r.URL)
+		}
+
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET " + bitbucketTestPR:
+			w.Write([]byte(`{"id":5,"source":{"commit":{"hash":"head1"}}}`))
+		case "GET " + bitbucketTestPR + "/diffstat":
+			if r.URL.Query().Get("page") == "2" {
+				w.Write([]byte(`{"values":[{"status":"removed","old":{"path":"gone.go"},"new":null},{"status":"added","new":{"path":"lib/new.go"}}]}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"values": []map[string]any{{"status": "modified", "new": map[string]string{"path": "main.go"}}},
+				"next":   server.URL + bitbucketTestPR + "/diffstat?page=2",
+			})
+		case "GET " + bitbucketTestPR + "/diff":
+			w.Write([]byte(testRawDiff))
+		case "GET /repositories/ws/repo/src/head1/lib/my%20file.go":
+			w.Write([]byte("package lib\n"))
+		case "POST " + bitbucketTestPR + "/comments":
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("invalid comment body: %s", err)
+			}
+			comments <- body
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":1}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv(env.EnvBitbucketToken, "secret")
+	t.Setenv(env.EnvBitbucketUsername, username)
+	t.Setenv(env.EnvBitbucketAPIURL, server.URL)
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The PR URL is on the host of the test server, so the run caches of the tests do not mix
+	return NewBitbucket(envManager), server.URL + "/ws/repo/pull-requests/5"
+}
+
+func TestBitbucketReadsAllPagesOfTheFiles(t *testing.T) {
+	client, prURL := newTestBitbucket(t, "", nil)
+
+	files, err := client.GetPRFiles(prURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 || files[0] != "main.go" || files[1] != "lib/new.go" {
+		t.Errorf("got files %v, want the deleted file left out", files)
+	}
+}
+
+func TestBitbucketSplitsTheDiff(t *testing.T) {
+	client, prURL := newTestBitbucket(t, "user", nil)
+
+	diffs, err := client.GetPRFileDiffs(prURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diffs) != 4 || diffs[0].Filename != "main.go" || diffs[3].Status != "renamed" {
+		t.Errorf("got diffs %+v", diffs)
+	}
+}
+
+func TestBitbucketReadsTheFileAtTheHeadCommit(t *testing.T) {
+	client, prURL := newTestBitbucket(t, "", nil)
+
+	content, err := client.GetPRFileContent(prURL, "lib/my file.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content != "package lib\n" {
+		t.Errorf("got content %q", content)
+	}
+}
+
+func TestBitbucketCreateCommentOnTheNewLine(t *testing.T) {
+	comments := make(chan map[string]any, 1)
+	client, prURL := newTestBitbucket(t, "", comments)
+
+	if err := client.CreateComment(prURL, "main.go", 2, "**minor** unused import"); err != nil {
+		t.Fatal(err)
+	}
+
+	body := <-comments
+	content, _ := body["content"].(map[string]any)
+	inline, _ := body["inline"].(map[string]any)
+	if content["raw"] != "**minor** unused import" || inline["path"] != "main.go" || inline["to"] != float64(2) {
+		t.Errorf("got comment %v", body)
+	}
+}
+
+func TestBitbucketReturnsAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"type":"error","error":{"message":"Access denied"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv(env.EnvBitbucketToken, "secret")
+	t.Setenv(env.EnvBitbucketAPIURL, server.URL)
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewBitbucket(envManager).GetPRFiles(server.URL + "/ws/repo/pull-requests/5"); err == nil {
+		t.Error("got no error for a forbidden pull request")
+	}
+}
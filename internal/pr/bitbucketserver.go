@@ -0,0 +1,203 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/git"
+)
+
+const bitbucketServerPageLimit = 100
+
+var (
+	bitbucketServerHeadCache  runCache[string]
+	bitbucketServerDiffsCache runCache[[]FileDiff]
+)
+
+// NewBitbucketServer creates a client of the Bitbucket Server / Data Center 1.0 REST API. The API URL is BITBUCKET_API_URL
+// if set, otherwise the API of the host of the PR URL. BITBUCKET_TOKEN is an HTTP access token, or a password if
+// BITBUCKET_USERNAME is set
+func NewBitbucketServer(env env.EnvironmentManager) *BitbucketServer {
+	return &BitbucketServer{
+		env: env,
+		client: newRESTClient("Bitbucket Server", time.Duration(env.ForgeTimeout())*time.Second, func(req *http.Request) {
+			if username := env.BitbucketUsername(); username != "" {
+				req.SetBasicAuth(username, env.BitbucketToken())
+				return
+			}
+
+			req.Header.Set("Authorization", "Bearer "+env.BitbucketToken())
+		}),
+	}
+}
+
+// BitbucketServer is the Bitbucket Server pull request client, it implements PullRequest
+type BitbucketServer struct {
+	env    env.EnvironmentManager
+	client restClient
+}
+
+// GetPRFiles implements PullRequest.
+func (b *BitbucketServer) GetPRFiles(prURL string) ([]string, error) {
+	base, err := b.base(prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	type changesPage struct {
+		Values []struct {
+			Type string `json:"type"`
+			Path struct {
+				ToString string `json:"toString"`
+			} `json:"path"`
+		} `json:"values"`
+		IsLastPage    bool `json:"isLastPage"`
+		NextPageStart int  `json:"nextPageStart"`
+	}
+
+	var fileNames []string
+	for start := 0; ; {
+		var page changesPage
+		if _, err := b.client.getJSON(fmt.Sprintf("%s/changes?limit=%d&start=%d", base, bitbucketServerPageLimit, start), &page); err != nil {
+			return nil, err
+		}
+
+		for _, value := range page.Values {
+			if value.Type == "DELETE" {
+				continue // skip deleted files
+			}
+
+			fileNames = append(fileNames, value.Path.ToString)
+		}
+
+		if page.IsLastPage || len(page.Values) == 0 {
+			break
+		}
+		start = page.NextPageStart
+	}
+
+	return fileNames, nil
+}
+
+// GetPRFileContent implements PullRequest, the file is read at the latest commit of the source branch
+func (b *BitbucketServer) GetPRFileContent(prURL, filePath string) (string, error) {
+	headSHA, err := b.HeadSHA(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	repoURL, _, err := b.repository(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	content, _, err := b.client.do(context.Background(), http.MethodGet, fmt.Sprintf("%s/raw/%s?at=%s", repoURL, escapePath(filePath), url.QueryEscape(headSHA)), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// GetPRFileDiffs implements PullRequest.
+func (b *BitbucketServer) GetPRFileDiffs(prURL string) ([]FileDiff, error) {
+	return bitbucketServerDiffsCache.get(prURL, func() ([]FileDiff, error) {
+		base, err := b.base(prURL)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, _, err := b.client.do(context.Background(), http.MethodGet, base+".diff", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return splitDiff(string(raw)), nil
+	})
+}
+
+// HeadSHA returns the latest commit of the source branch of the pull request, it is fetched once per run
+func (b *BitbucketServer) HeadSHA(prURL string) (string, error) {
+	return bitbucketServerHeadCache.get(prURL, func() (string, error) {
+		base, err := b.base(prURL)
+		if err != nil {
+			return "", err
+		}
+
+		var result struct {
+			FromRef struct {
+				LatestCommit string `json:"latestCommit"`
+			} `json:"fromRef"`
+		}
+
+		if _, err := b.client.getJSON(base, &result); err != nil {
+			return "", err
+		}
+
+		return result.FromRef.LatestCommit, nil
+	})
+}
+
+// CreateComment posts an inline comment on a line of the new version of the file. The line is anchored as added
+// or as context, looked up in the diff of the pull request
+func (b *BitbucketServer) CreateComment(prURL, filePath string, line int, body string) error {
+	base, err := b.base(prURL)
+	if err != nil {
+		return err
+	}
+
+	lineType := "CONTEXT"
+	diffs, err := b.GetPRFileDiffs(prURL)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range diffs {
+		if d.Filename == filePath {
+			if added, _, _ := diffLine(d.Patch, line); added {
+				lineType = "ADDED"
+			}
+			break
+		}
+	}
+
+	return b.client.postJSON(base+"/comments", map[string]interface{}{
+		"text": body,
+		"anchor": map[string]interface{}{
+			"path":     filePath,
+			"line":     line,
+			"lineType": lineType,
+			"fileType": "TO",
+			"diffType": "EFFECTIVE",
+		},
+	})
+}
+
+// repository returns the API URL of the repository and the PR number of the PR URL
+func (b *BitbucketServer) repository(prURL string) (string, int, error) {
+	location, err := git.GetBitbucketServerPRInfo(prURL)
+	if err != nil {
+		return "", 0, err
+	}
+
+	apiURL := b.env.BitbucketAPIURL()
+	if apiURL == "" {
+		apiURL = location.HostURL + "/rest/api/1.0"
+	}
+
+	return fmt.Sprintf("%s/projects/%s/repos/%s", apiURL, location.Owner, location.Repo), location.Number, nil
+}
+
+// base returns the API URL of the pull request
+func (b *BitbucketServer) base(prURL string) (string, error) {
+	repoURL, prNumber, err := b.repository(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/pull-requests/%d", repoURL, prNumber), nil
+}
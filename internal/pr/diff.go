@@ -0,0 +1,148 @@
+package pr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitDiff splits the raw unified diff of a pull request into file diffs, the patch of each file starts at its first hunk,
+// like the patches of the GitHub API
+func splitDiff(raw string) []FileDiff {
+	var diffs []FileDiff
+	var current *FileDiff
+	var patch []string
+	inHunks := false
+
+	flush := func() {
+		if current != nil {
+			current.Patch = strings.Join(patch, "\n")
+			diffs = append(diffs, *current)
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			current = &FileDiff{Filename: gitHeaderPath(line), Status: "modified"}
+			patch = nil
+			inHunks = false
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		if inHunks || strings.HasPrefix(line, "@@") {
+			inHunks = true
+			patch = append(patch, line)
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "new file mode"):
+			current.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode"):
+			current.Status = "removed"
+		case strings.HasPrefix(line, "rename to "):
+			current.Status = "renamed"
+			current.Filename = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "+++ b/"):
+			current.Filename = strings.TrimPrefix(line, "+++ b/")
+		}
+	}
+	flush()
+
+	return diffs
+}
+
+// gitHeaderPath returns the new path of the diff --git a/<old> b/<new> header
+func gitHeaderPath(header string) string {
+	paths := strings.TrimPrefix(header, "diff --git ")
+	if i := strings.LastIndex(paths, " b/"); i != -1 {
+		return paths[i+3:]
+	}
+
+	return paths
+}
+
+// diffLine tells where a line of the new version of the file is in the patch. Added is true for added lines,
+// oldLine is the line in the old version for unchanged lines, found is false if the line is not in the patch
+func diffLine(patch string, newLine int) (added bool, oldLine int, found bool) {
+	var oldLineNr, newLineNr int
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			// @@ -a,b +c,d @@
+			fmt.Sscanf(line, "@@ -%d", &oldLineNr)
+			if _, newPart, ok := strings.Cut(line, " +"); ok {
+				fmt.Sscanf(newPart, "%d", &newLineNr)
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "+"):
+			if newLineNr == newLine {
+				return true, 0, true
+			}
+			newLineNr++
+		case strings.HasPrefix(line, "-"):
+			oldLineNr++
+		case strings.HasPrefix(line, " "):
+			if newLineNr == newLine {
+				return false, oldLineNr, true
+			}
+			oldLineNr++
+			newLineNr++
+		}
+	}
+
+	return false, 0, false
+}
+
+// inOneHunk tells if the lines from start to end of the new version of the file are all in the same hunk of the patch
+func inOneHunk(patch string, start, end int) bool {
+	for _, line := range strings.Split(patch, "\n") {
+		if !strings.HasPrefix(line, "@@") {
+			continue
+		}
+
+		// @@ -a,b +c,d @@, the count is 1 if omitted
+		_, newPart, ok := strings.Cut(line, " +")
+		if !ok {
+			continue
+		}
+
+		newStart, newCount := 0, 1
+		if n, _ := fmt.Sscanf(newPart, "%d,%d", &newStart, &newCount); n == 0 {
+			continue
+		}
+
+		if newCount > 0 && start >= newStart && end <= newStart+newCount-1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hunkAt returns the hunk of the patch containing the line of the new version of the file, empty if there is none
+func hunkAt(patch string, line int) string {
+	var hunks []string
+	for _, patchLine := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(patchLine, "@@"):
+			hunks = append(hunks, patchLine)
+		case len(hunks) > 0:
+			hunks[len(hunks)-1] += "\n" + patchLine
+		}
+	}
+
+	for _, hunk := range hunks {
+		if inOneHunk(hunk, line, line) {
+			return strings.TrimRight(hunk, "\n")
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,71 @@
+package pr
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testRawDiff is the raw diff of a pull request, main.go is modified, lib/new.go added, gone.go deleted
+// and util.go renamed to helpers.go
+const testRawDiff = "diff --git a/main.go b/main.go\n" +
+	"index 1111111..2222222 100644\n" +
+	"--- a/main.go\n" +
+	"+++ b/main.go\n" +
+	"@@ -1,3 +1,4 @@\n" +
+	" package main\n" +
+	"+import \"fmt\"\n" +
+	" \n" +
+	" func main() {}\n" +
+	"diff --git a/lib/new.go b/lib/new.go\n" +
+	"new file mode 100644\n" +
+	"index 0000000..3333333\n" +
+	"--- /dev/null\n" +
+	"+++ b/lib/new.go\n" +
+	"@@ -0,0 +1 @@\n" +
+	"+package lib\n" +
+	"diff --git a/gone.go b/gone.go\n" +
+	"deleted file mode 100644\n" +
+	"index 4444444..0000000\n" +
+	"--- a/gone.go\n" +
+	"+++ /dev/null\n" +
+	"@@ -1 +0,0 @@\n" +
+	"-package gone\n" +
+	"diff --git a/util.go b/helpers.go\n" +
+	"similarity index 90%\n" +
+	"rename from util.go\n" +
+	"rename to helpers.go\n" +
+	"--- a/util.go\n" +
+	"+++ b/helpers.go\n" +
+	"@@ -10,2 +10,2 @@\n" +
+	"-a := 1\n" +
+	"+a := 2\n" +
+	" return a\n"
+
+func TestSplitDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []FileDiff
+	}{
+		{"empty", "", nil},
+		{"no git header", "@@ -1 +1 @@\n-a\n+b\n", nil},
+		{"pull request", testRawDiff, []FileDiff{
+			{Filename: "main.go", Status: "modified", Patch: "@@ -1,3 +1,4 @@\n package main\n+import \"fmt\"\n \n func main() {}"},
+			{Filename: "lib/new.go", Status: "added", Patch: "@@ -0,0 +1 @@\n+package lib"},
+			{Filename: "gone.go", Status: "removed", Patch: "@@ -1 +0,0 @@\n-package gone"},
+			{Filename: "helpers.go", Status: "renamed", Patch: "@@ -10,2 +10,2 @@\n-a := 1\n+a := 2\n return a\n"},
+		}},
+		{"windows line endings", "diff --git a/a.go b/a.go\r\n--- a/a.go\r\n+++ b/a.go\r\n@@ -1 +1 @@\r\n-x\r\n+y", []FileDiff{
+			{Filename: "a.go", Status: "modified", Patch: "@@ -1 +1 @@\n-x\n+y"},
+		}},
+		{"binary file without hunks", "diff --git a/logo.png b/logo.png\nBinary files a/logo.png and b/logo.png differ", []FileDiff{
+			{Filename: "logo.png", Status: "modified"},
+		}},
+	}
+
+	for _, test := range tests {
+		if got := splitDiff(test.raw); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/git"
+)
+
+var (
+	giteaHeadCache  runCache[string]
+	giteaDiffsCache runCache[[]FileDiff]
+)
+
+// NewGitea creates a client of the Gitea v1 API, Forgejo serves the same API. The API URL is GITEA_API_URL
+// if set, otherwise the API of the host of the PR URL
+func NewGitea(env env.EnvironmentManager) *Gitea {
+	return &Gitea{
+		env: env,
+		client: newRESTClient("Gitea", time.Duration(env.ForgeTimeout())*time.Second, func(req *http.Request) {
+			req.Header.Set("Authorization", "token "+env.GiteaToken())
+		}),
+	}
+}
+
+// Gitea is the Gitea and Forgejo pull request client, it implements PullRequest
+type Gitea struct {
+	env    env.EnvironmentManager
+	client restClient
+}
+
+// GetPRFiles implements PullRequest, the files are taken from the diff of the pull request
+func (g *Gitea) GetPRFiles(prURL string) ([]string, error) {
+	diffs, err := g.GetPRFileDiffs(prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileNames []string
+	for _, d := range diffs {
+		if d.Status == "removed" {
+			continue // skip deleted files
+		}
+
+		fileNames = append(fileNames, d.Filename)
+	}
+
+	return fileNames, nil
+}
+
+// GetPRFileContent implements PullRequest, the file is read at the head commit of the pull request
+func (g *Gitea) GetPRFileContent(prURL, filePath string) (string, error) {
+	headSHA, err := g.HeadSHA(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	repoURL, _, err := g.repository(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	content, _, err := g.client.do(context.Background(), http.MethodGet, fmt.Sprintf("%s/raw/%s?ref=%s", repoURL, escapePath(filePath), url.QueryEscape(headSHA)), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// GetPRFileDiffs implements PullRequest.
+func (g *Gitea) GetPRFileDiffs(prURL string) ([]FileDiff, error) {
+	return giteaDiffsCache.get(prURL, func() ([]FileDiff, error) {
+		repoURL, prNumber, err := g.repository(prURL)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, _, err := g.client.do(context.Background(), http.MethodGet, fmt.Sprintf("%s/pulls/%d.diff", repoURL, prNumber), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return splitDiff(string(raw)), nil
+	})
+}
+
+// HeadSHA returns the head commit of the pull request, it is fetched once per run
+func (g *Gitea) HeadSHA(prURL string) (string, error) {
+	return giteaHeadCache.get(prURL, func() (string, error) {
+		repoURL, prNumber, err := g.repository(prURL)
+		if err != nil {
+			return "", err
+		}
+
+		var result struct {
+			Head struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		}
+
+		if _, err := g.client.getJSON(fmt.Sprintf("%s/pulls/%d", repoURL, prNumber), &result); err != nil {
+			return "", err
+		}
+
+		return result.Head.SHA, nil
+	})
+}
+
+// CreateComment posts a review with a single comment on a line of the new version of the file
+func (g *Gitea) CreateComment(prURL, filePath string, line int, body string) error {
+	headSHA, err := g.HeadSHA(prURL)
+	if err != nil {
+		return err
+	}
+
+	repoURL, prNumber, err := g.repository(prURL)
+	if err != nil {
+		return err
+	}
+
+	return g.client.postJSON(fmt.Sprintf("%s/pulls/%d/reviews", repoURL, prNumber), map[string]interface{}{
+		"commit_id": headSHA,
+		"event":     "COMMENT",
+		"body":      "",
+		"comments": []map[string]interface{}{{
+			"path":         filePath,
+			"body":         body,
+			"new_position": line,
+		}},
+	})
+}
+
+// repository returns the API URL of the repository and the PR number of the PR URL
+func (g *Gitea) repository(prURL string) (string, int, error) {
+	location, err := git.GetGiteaPRInfo(prURL)
+	if err != nil {
+		return "", 0, err
+	}
+
+	apiURL := g.env.GiteaAPIURL()
+	if apiURL == "" {
+		apiURL = location.HostURL + "/api/v1"
+	}
+
+	return fmt.Sprintf("%s/repos/%s/%s", apiURL, location.Owner, location.Repo), location.Number, nil
+}
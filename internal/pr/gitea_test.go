@@ -0,0 +1,144 @@
+package pr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/env"
+)
+
+const giteaTestRepo = "/api/v1/repos/owner/repo"
+
+// newTestGitea serves a pull request on an httptest server, and returns the client with the URL of the pull request.
+// The API is on the host of the PR URL unless apiPath is set, the bodies of the created reviews are sent to the reviews channel
+func newTestGitea(t *testing.T, apiPath string, reviews chan<- map[string]any) (*Gitea, string) {
+	t.Helper()
+
+	repo := giteaTestRepo
+	if apiPath != "" {
+		repo = apiPath + "/repos/owner/repo"
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			t.Errorf("got authorization %q on %s", r.Header.Get("Authorization"), r.URL)
+		}
+
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET " + repo + "/pulls/9":
+			w.Write([]byte(`{"number":9,"head":{"sha":"head1"}}`))
+		case "GET " + repo + "/pulls/9.diff":
+			w.Write([]byte(testRawDiff))
+		case "GET " + repo + "/raw/lib/new.go":
+			if r.URL.Query().Get("ref") != "head1" {
+				t.Errorf("got ref %q, want the head commit", r.URL.Query().Get("ref"))
+			}
+			w.Write([]byte("package lib\n"))
+		case "POST " + repo + "/pulls/9/reviews":
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("invalid review body: %s", err)
+			}
+			reviews <- body
+			w.Write([]byte(`{"id":1}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv(env.EnvGiteaToken, "secret")
+	t.Setenv(env.EnvGiteaAPIURL, "")
+	if apiPath != "" {
+		t.Setenv(env.EnvGiteaAPIURL, server.URL+apiPath)
+	}
+
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every test server has its own host, so the run caches of the tests do not mix
+	return NewGitea(envManager), server.URL + "/owner/repo/pulls/9"
+}
+
+func TestGiteaFilesOfTheDiff(t *testing.T) {
+	client, prURL := newTestGitea(t, "", nil)
+
+	diffs, err := client.GetPRFileDiffs(prURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diffs) != 4 || diffs[1].Status != "added" || diffs[2].Status != "removed" {
+		t.Errorf("got diffs %+v", diffs)
+	}
+
+	files, err := client.GetPRFiles(prURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 3 || files[0] != "main.go" || files[1] != "lib/new.go" || files[2] != "helpers.go" {
+		t.Errorf("got files %v, want the deleted file left out", files)
+	}
+}
+
+func TestGiteaReadsTheFileAtTheHeadCommit(t *testing.T) {
+	client, prURL := newTestGitea(t, "", nil)
+
+	content, err := client.GetPRFileContent(prURL, "lib/new.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content != "package lib\n" {
+		t.Errorf("got content %q", content)
+	}
+}
+
+func TestGiteaCreateCommentPostsAReview(t *testing.T) {
+	reviews := make(chan map[string]any, 1)
+	client, prURL := newTestGitea(t, "/forgejo/api/v1", reviews)
+
+	if err := client.CreateComment(prURL, "main.go", 2, "**minor** unused import"); err != nil {
+		t.Fatal(err)
+	}
+
+	body := <-reviews
+	if body["commit_id"] != "head1" || body["event"] != "COMMENT" {
+		t.Errorf("got review %v", body)
+	}
+
+	comments, _ := body["comments"].([]any)
+	if len(comments) != 1 {
+		t.Fatalf("got comments %v, want one", body["comments"])
+	}
+
+	comment, _ := comments[0].(map[string]any)
+	if comment["path"] != "main.go" || comment["body"] != "**minor** unused import" || comment["new_position"] != float64(2) {
+		t.Errorf("got comment %v", comment)
+	}
+}
+
+func TestGiteaReturnsAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"The target couldn't be found."}`))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv(env.EnvGiteaToken, "secret")
+	t.Setenv(env.EnvGiteaAPIURL, "")
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewGitea(envManager).GetPRFiles(server.URL + "/owner/repo/pulls/9"); err == nil {
+		t.Error("got no error for a missing pull request")
+	}
+}
@@ -5,42 +5,93 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/olbrichattila/qreview/internal/env"
 	"github.com/olbrichattila/qreview/internal/git"
 )
 
-func newGitHub(env env.EnvironmentManager) PullRequest {
-	return &gitHubPr{env: env}
-}
+const (
+	gitHubDotComAPIURL = "https://api.github.com"
+	gitHubPerPage      = 100
+)
 
-type gitHubPr struct {
-	env env.EnvironmentManager
-}
+var (
+	gitHubHeadCache  runCache[string]
+	gitHubFilesCache runCache[[]FileDiff]
+)
 
-func (g *gitHubPr) GetPRFiles(prURL string) ([]string, error) {
-	owner, repo, pullNumber, err := git.GetPRInfo(prURL)
+// GitHubRepositoryURL returns the API URL of the repository and the PR number of the PR URL. The API is GITHUB_API_URL
+// if set, https://api.github.com for github.com, and https://<host>/api/v3 for a GitHub Enterprise Server host
+func GitHubRepositoryURL(env env.EnvironmentManager, prURL string) (string, int, error) {
+	owner, repo, prNumber, err := git.GetPRInfo(prURL)
 	if err != nil {
-		return nil, err
+		return "", 0, err
 	}
 
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/files", owner, repo, pullNumber)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "Bearer "+g.env.GithubToken())
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	apiURL := env.GithubAPIURL()
+	if apiURL == "" {
+		hostURL, err := git.GetPRHostURL(prURL)
+		if err != nil {
+			return "", 0, err
+		}
+
+		apiURL = gitHubDotComAPIURL
+		if !strings.EqualFold(hostURL, "https://github.com") && !strings.EqualFold(hostURL, "http://github.com") {
+			apiURL = hostURL + "/api/v3"
+		}
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	return fmt.Sprintf("%s/repos/%s/%s", apiURL, owner, repo), prNumber, nil
+}
+
+type FileDiff struct {
+	Filename string `json:"filename"`
+	Status   string `json:"status"`
+	Patch    string `json:"patch,omitempty"`
+}
+
+// gitHubGraphQLURL returns the GraphQL endpoint next to the REST API of the PR, https://<host>/api/graphql on GitHub Enterprise Server
+func gitHubGraphQLURL(env env.EnvironmentManager, prURL string) (string, error) {
+	repoURL, _, err := GitHubRepositoryURL(env, prURL)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	apiURL, _, _ := strings.Cut(repoURL, "/repos/")
+	if strings.HasSuffix(apiURL, "/api/v3") {
+		return strings.TrimSuffix(apiURL, "/v3") + "/graphql", nil
 	}
-	defer resp.Body.Close()
 
-	var files []struct {
-		Filename string `json:"filename"`
-		Status   string `json:"status"`
+	return apiURL + "/graphql", nil
+}
+
+// NewGitHub creates a client of the GitHub REST API, shared by the PR source and the commenter
+func NewGitHub(env env.EnvironmentManager) *GitHub {
+	return &GitHub{
+		env: env,
+		client: newRESTClient("GitHub", time.Duration(env.ForgeTimeout())*time.Second, func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+env.GithubToken())
+			req.Header.Set("Accept", "application/vnd.github+json")
+			req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		}),
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&files); err != nil {
+}
+
+// GitHub is the pull request client, it implements PullRequest and ThreadReader
+type GitHub struct {
+	env    env.EnvironmentManager
+	client restClient
+}
+
+// GetPRFiles implements PullRequest.
+func (g *GitHub) GetPRFiles(prURL string) ([]string, error) {
+	files, err := g.GetPRFileDiffs(prURL)
+	if err != nil {
 		return nil, err
 	}
 
@@ -55,38 +106,30 @@ func (g *gitHubPr) GetPRFiles(prURL string) ([]string, error) {
 	return fileNames, nil
 }
 
-func (g *gitHubPr) GetPRFileContent(prURL, filePath string) (string, error) {
-	owner, repo, prNumber, err := git.GetPRInfo(prURL)
+// GetPRFileContent implements PullRequest, the file is read at the head commit of the pull request
+func (g *GitHub) GetPRFileContent(prURL, filePath string) (string, error) {
+	repoURL, _, err := GitHubRepositoryURL(g.env, prURL)
 	if err != nil {
 		return "", err
 	}
 
-	ref, err := g.getPRHeadSHA(g.env.GithubToken(), owner, repo, prNumber)
+	ref, err := g.HeadSHA(prURL)
 	if err != nil {
 		return "", err
 	}
 
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s?ref=%s", owner, repo, filePath, ref)
-	req, _ := http.NewRequest("GET", url, nil)
-
-	req.Header.Set("Authorization", "Bearer "+g.env.GithubToken())
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
 	var contentResp struct {
 		Content  string `json:"content"`
 		Encoding string `json:"encoding"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&contentResp); err != nil {
+	if _, err := g.client.getJSON(fmt.Sprintf("%s/contents/%s?ref=%s", repoURL, escapePath(filePath), url.QueryEscape(ref)), &contentResp); err != nil {
 		return "", err
 	}
 
+	if contentResp.Encoding != "base64" {
+		return "", fmt.Errorf("GitHub returned %s with %q encoding, files above 1 MB are not supported", filePath, contentResp.Encoding)
+	}
+
 	decoded, err := base64.StdEncoding.DecodeString(contentResp.Content)
 	if err != nil {
 		return "", err
@@ -94,68 +137,340 @@ func (g *gitHubPr) GetPRFileContent(prURL, filePath string) (string, error) {
 	return string(decoded), nil
 }
 
-type FileDiff struct {
-	Filename string `json:"filename"`
-	Status   string `json:"status"`
-	Patch    string `json:"patch,omitempty"`
+// GetPRFileDiffs implements PullRequest, all the pages of the files of the pull request are fetched once per run
+func (g *GitHub) GetPRFileDiffs(prURL string) ([]FileDiff, error) {
+	return gitHubFilesCache.get(prURL, func() ([]FileDiff, error) {
+		repoURL, pullNumber, err := GitHubRepositoryURL(g.env, prURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return getPages[FileDiff](g.client, fmt.Sprintf("%s/pulls/%d/files?per_page=%d", repoURL, pullNumber, gitHubPerPage))
+	})
+}
+
+// HeadSHA returns the head commit of the pull request, it is fetched once per run
+func (g *GitHub) HeadSHA(prURL string) (string, error) {
+	return gitHubHeadCache.get(prURL, func() (string, error) {
+		repoURL, prNumber, err := GitHubRepositoryURL(g.env, prURL)
+		if err != nil {
+			return "", err
+		}
+
+		var result struct {
+			Head struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		}
+
+		if _, err := g.client.getJSON(fmt.Sprintf("%s/pulls/%d", repoURL, prNumber), &result); err != nil {
+			return "", err
+		}
+
+		return result.Head.SHA, nil
+	})
+}
+
+// Review events of the Pull Request Reviews API
+const (
+	ReviewEventComment        = "COMMENT"
+	ReviewEventRequestChanges = "REQUEST_CHANGES"
+)
+
+// ReviewComment is an inline comment of a pull request review, on a line of the new version of the file
+type ReviewComment struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line,omitempty"` // First line of a multi-line comment, Line is the last one
+	StartSide string `json:"start_side,omitempty"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	Body      string `json:"body"`
+}
+
+// CreateReview submits a review with its inline comments at the head commit, in a single request
+func (g *GitHub) CreateReview(prURL, body, event string, comments []ReviewComment) error {
+	repoURL, prNumber, err := GitHubRepositoryURL(g.env, prURL)
+	if err != nil {
+		return err
+	}
+
+	commitSHA, err := g.HeadSHA(prURL)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		comments[i].Side = "RIGHT"
+		if comments[i].StartLine > 0 {
+			comments[i].StartSide = "RIGHT"
+		}
+	}
+
+	return g.client.postJSON(fmt.Sprintf("%s/pulls/%d/reviews", repoURL, prNumber), map[string]interface{}{
+		"commit_id": commitSHA,
+		"body":      body,
+		"event":     event,
+		"comments":  comments,
+	})
 }
 
-func (g *gitHubPr) GetPRFileDiffs(prURL string) ([]FileDiff, error) {
-	owner, repo, pullNumber, err := git.GetPRInfo(prURL)
+// PRComment is a review comment already on the pull request
+type PRComment struct {
+	ID        int64  `json:"id"`
+	NodeID    string `json:"node_id"`        // ID of the comment in the GraphQL API
+	InReplyTo int64  `json:"in_reply_to_id"` // The first comment of the thread, 0 for the first comment itself
+	Path      string `json:"path"`
+	Line      int    `json:"line"` // 0 if the line is gone from the diff
+	DiffHunk  string `json:"diff_hunk"`
+	Body      string `json:"body"`
+	User      struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// ReviewComments lists all the review comments of the pull request
+func (g *GitHub) ReviewComments(prURL string) ([]PRComment, error) {
+	repoURL, prNumber, err := GitHubRepositoryURL(g.env, prURL)
 	if err != nil {
 		return nil, err
 	}
 
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/files", owner, repo, pullNumber)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "Bearer "+g.env.GithubToken())
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	return getPages[PRComment](g.client, fmt.Sprintf("%s/pulls/%d/comments?per_page=%d", repoURL, prNumber, gitHubPerPage))
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// ReviewBodies lists the bodies of all the reviews of the pull request
+func (g *GitHub) ReviewBodies(prURL string) ([]string, error) {
+	repoURL, prNumber, err := GitHubRepositoryURL(g.env, prURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var diffs []FileDiff
-	if err := json.NewDecoder(resp.Body).Decode(&diffs); err != nil {
+	reviews, err := getPages[struct {
+		Body string `json:"body"`
+	}](g.client, fmt.Sprintf("%s/pulls/%d/reviews?per_page=%d", repoURL, prNumber, gitHubPerPage))
+	if err != nil {
 		return nil, err
 	}
-	return diffs, nil
-}
 
-// getPRHeadSHA fetches the head commit SHA of a GitHub PR
-func (g *gitHubPr) getPRHeadSHA(token, owner, repo string, prNumber int) (string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d", owner, repo, prNumber)
+	bodies := make([]string, 0, len(reviews))
+	for _, review := range reviews {
+		bodies = append(bodies, review.Body)
+	}
+
+	return bodies, nil
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// UpdateReviewComment replaces the body of a review comment
+func (g *GitHub) UpdateReviewComment(prURL string, commentID int64, body string) error {
+	repoURL, _, err := GitHubRepositoryURL(g.env, prURL)
 	if err != nil {
-		return "", err
+		return err
 	}
-	req.Header.Set("Authorization", "token "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	_, err = g.client.sendJSON(http.MethodPatch, fmt.Sprintf("%s/pulls/comments/%d", repoURL, commentID), map[string]string{"body": body})
+	return err
+}
+
+// MinimizeComment hides the comment as outdated, it is only available in the GraphQL API
+func (g *GitHub) MinimizeComment(prURL, nodeID string) error {
+	graphQLURL, err := gitHubGraphQLURL(g.env, prURL)
 	if err != nil {
-		return "", err
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GitHub API returned %s", resp.Status)
+	content, err := g.client.sendJSON(http.MethodPost, graphQLURL, map[string]interface{}{
+		"query":     "mutation($id: ID!) { minimizeComment(input: {subjectId: $id, classifier: OUTDATED}) { clientMutationId } }",
+		"variables": map[string]string{"id": nodeID},
+	})
+	if err != nil {
+		return err
 	}
 
+	// GraphQL reports the errors with a 200 response
 	var result struct {
-		Head struct {
-			SHA string `json:"sha"`
-		} `json:"head"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return fmt.Errorf("could not parse GitHub GraphQL response: %w", err)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("GitHub GraphQL API returned: %s", result.Errors[0].Message)
+	}
+
+	return nil
+}
+
+// InOneHunk tells if the lines from start to end of the new version of the file are in a single hunk of the diff,
+// a suggested change can only replace such a range
+func (g *GitHub) InOneHunk(prURL, filePath string, start, end int) (bool, error) {
+	files, err := g.GetPRFileDiffs(prURL)
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range files {
+		if f.Filename == filePath {
+			return inOneHunk(f.Patch, start, end), nil
+		}
+	}
+
+	return false, nil
+}
+
+// InDiff tells if the line of the new version of the file is in the diff of the pull request, only those lines can have review comments
+func (g *GitHub) InDiff(prURL, filePath string, line int) (bool, error) {
+	files, err := g.GetPRFileDiffs(prURL)
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range files {
+		if f.Filename == filePath {
+			_, _, found := diffLine(f.Patch, line)
+			return found, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ReviewThreads implements ThreadReader, the replies of a thread are the review comments answering its first comment
+func (g *GitHub) ReviewThreads(prURL string) ([]Thread, error) {
+	comments, err := g.ReviewComments(prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+
+	var threads []Thread
+	byID := map[int64]int{}
+	for _, comment := range comments {
+		threadComment := ThreadComment{
+			ID:     strconv.FormatInt(comment.ID, 10),
+			Author: comment.User.Login,
+			Body:   comment.Body,
+		}
+
+		if i, ok := byID[comment.InReplyTo]; ok {
+			threads[i].Comments = append(threads[i].Comments, threadComment)
+			continue
+		}
+
+		byID[comment.ID] = len(threads)
+		threads = append(threads, Thread{
+			ID:       threadComment.ID,
+			FilePath: comment.Path,
+			Line:     comment.Line,
+			DiffHunk: comment.DiffHunk,
+			Comments: []ThreadComment{threadComment},
+		})
+	}
+
+	return threads, nil
+}
+
+// ReplyToThread answers the review thread starting with the comment of the thread ID
+func (g *GitHub) ReplyToThread(prURL, threadID, body string) error {
+	repoURL, prNumber, err := GitHubRepositoryURL(g.env, prURL)
+	if err != nil {
+		return err
+	}
+
+	return g.client.postJSON(fmt.Sprintf("%s/pulls/%d/comments/%s/replies", repoURL, prNumber, url.PathEscape(threadID)), map[string]string{"body": body})
+}
+
+// checkRunMaxAnnotations is the limit of the annotations of a single check run request
+const checkRunMaxAnnotations = 50
+
+// CheckAnnotation is an annotation of a check run, on lines of the new version of a file
+type CheckAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"` // notice, warning or failure
+	Title           string `json:"title,omitempty"`
+	Message         string `json:"message"`
+	RawDetails      string `json:"raw_details,omitempty"`
+}
+
+// CheckOutput is the result shown on the page of a check run
+type CheckOutput struct {
+	Title       string            `json:"title"`
+	Summary     string            `json:"summary"`
+	Annotations []CheckAnnotation `json:"annotations"`
+}
+
+// CreateCheckRun creates a completed check run on the head commit. The annotations are sent in batches, the first one
+// with the check run, the others by updating it
+func (g *GitHub) CreateCheckRun(prURL, name, conclusion string, output CheckOutput) error {
+	repoURL, _, err := GitHubRepositoryURL(g.env, prURL)
+	if err != nil {
+		return err
+	}
+
+	headSHA, err := g.HeadSHA(prURL)
+	if err != nil {
+		return err
+	}
+
+	annotations := output.Annotations
+	batch := func() CheckOutput {
+		n := min(len(annotations), checkRunMaxAnnotations)
+		batchOutput := output
+		batchOutput.Annotations = annotations[:n]
+		annotations = annotations[n:]
+
+		return batchOutput
+	}
+
+	content, err := g.client.sendJSON(http.MethodPost, repoURL+"/check-runs", map[string]interface{}{
+		"name":       name,
+		"head_sha":   headSHA,
+		"status":     "completed",
+		"conclusion": conclusion,
+		"output":     batch(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var checkRun struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(content, &checkRun); err != nil {
+		return fmt.Errorf("could not parse GitHub check run response: %w", err)
+	}
+
+	for len(annotations) > 0 {
+		if _, err := g.client.sendJSON(http.MethodPatch, fmt.Sprintf("%s/check-runs/%d", repoURL, checkRun.ID), map[string]interface{}{
+			"output": batch(),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateStatus sets a commit status on the head commit, for tokens which cannot create check runs
+func (g *GitHub) CreateStatus(prURL, state, statusContext, description string) error {
+	repoURL, _, err := GitHubRepositoryURL(g.env, prURL)
+	if err != nil {
+		return err
+	}
+
+	headSHA, err := g.HeadSHA(prURL)
+	if err != nil {
+		return err
 	}
 
-	return result.Head.SHA, nil
+	return g.client.postJSON(fmt.Sprintf("%s/statuses/%s", repoURL, headSHA), map[string]string{
+		"state":       state,
+		"context":     statusContext,
+		"description": description,
+	})
 }
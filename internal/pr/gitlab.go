@@ -0,0 +1,357 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/git"
+)
+
+const gitlabPerPage = 100
+
+var (
+	gitlabMRCache    runCache[MergeRequest]
+	gitlabDiffsCache runCache[[]gitlabDiff]
+)
+
+// DiffRefs are the commits of a GitLab merge request version, inline discussions are positioned by them
+type DiffRefs struct {
+	BaseSHA  string `json:"base_sha"`
+	StartSHA string `json:"start_sha"`
+	HeadSHA  string `json:"head_sha"`
+}
+
+// MergeRequest is the part of the GitLab merge request used by the review
+type MergeRequest struct {
+	IID      int      `json:"iid"`
+	DiffRefs DiffRefs `json:"diff_refs"`
+}
+
+// Position is the place of an inline discussion, OldLine is set for unchanged lines only
+type Position struct {
+	NewPath string
+	OldPath string
+	NewLine int
+	OldLine int
+}
+
+type gitlabDiff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	Diff        string `json:"diff"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+}
+
+// NewGitLab creates a client of the GitLab v4 API. The API URL is GITLAB_API_URL if set,
+// otherwise the API of the host of the MR URL, so a self-managed instance needs no configuration
+func NewGitLab(env env.EnvironmentManager) *GitLab {
+	return &GitLab{
+		env: env,
+		client: newRESTClient("GitLab", time.Duration(env.ForgeTimeout())*time.Second, func(req *http.Request) {
+			req.Header.Set("PRIVATE-TOKEN", env.GitlabToken())
+		}),
+	}
+}
+
+// GitLab is the merge request client, it implements PullRequest and ThreadReader
+type GitLab struct {
+	env    env.EnvironmentManager
+	client restClient
+}
+
+// GetPRFiles implements PullRequest.
+func (g *GitLab) GetPRFiles(mrURL string) ([]string, error) {
+	diffs, err := g.diffs(mrURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileNames []string
+	for _, d := range diffs {
+		if d.DeletedFile {
+			continue // skip deleted files
+		}
+
+		fileNames = append(fileNames, d.NewPath)
+	}
+
+	return fileNames, nil
+}
+
+// GetPRFileContent implements PullRequest, the file is read at the head commit of the merge request
+func (g *GitLab) GetPRFileContent(mrURL, filePath string) (string, error) {
+	mr, err := g.MergeRequest(mrURL)
+	if err != nil {
+		return "", err
+	}
+
+	apiURL, projectID, _, err := g.project(mrURL)
+	if err != nil {
+		return "", err
+	}
+
+	content, _, err := g.client.do(context.Background(), http.MethodGet, fmt.Sprintf(
+		"%s/projects/%s/repository/files/%s/raw?ref=%s",
+		apiURL,
+		projectID,
+		url.PathEscape(filePath),
+		url.QueryEscape(mr.DiffRefs.HeadSHA),
+	), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// GetPRFileDiffs implements PullRequest.
+func (g *GitLab) GetPRFileDiffs(mrURL string) ([]FileDiff, error) {
+	diffs, err := g.diffs(mrURL)
+	if err != nil {
+		return nil, err
+	}
+
+	fileDiffs := make([]FileDiff, 0, len(diffs))
+	for _, d := range diffs {
+		fileDiffs = append(fileDiffs, FileDiff{
+			Filename: d.NewPath,
+			Status:   gitlabStatus(d),
+			Patch:    d.Diff,
+		})
+	}
+
+	return fileDiffs, nil
+}
+
+// MergeRequest returns the merge request with its diff refs, it is fetched once per run
+func (g *GitLab) MergeRequest(mrURL string) (MergeRequest, error) {
+	return gitlabMRCache.get(mrURL, func() (MergeRequest, error) {
+		apiURL, projectID, mrIID, err := g.project(mrURL)
+		if err != nil {
+			return MergeRequest{}, err
+		}
+
+		var mr MergeRequest
+		if _, err := g.client.getJSON(fmt.Sprintf("%s/projects/%s/merge_requests/%d", apiURL, projectID, mrIID), &mr); err != nil {
+			return MergeRequest{}, err
+		}
+
+		if mr.DiffRefs.HeadSHA == "" {
+			return MergeRequest{}, fmt.Errorf("the GitLab merge request %s has no diff refs yet", mrURL)
+		}
+
+		return mr, nil
+	})
+}
+
+// CreateDiscussion starts an inline discussion on the merge request at the position
+func (g *GitLab) CreateDiscussion(mrURL, body string, position Position) error {
+	mr, err := g.MergeRequest(mrURL)
+	if err != nil {
+		return err
+	}
+
+	apiURL, projectID, mrIID, err := g.project(mrURL)
+	if err != nil {
+		return err
+	}
+
+	oldPath := position.OldPath
+	if oldPath == "" {
+		oldPath = position.NewPath
+	}
+
+	requestPosition := map[string]interface{}{
+		"position_type": "text",
+		"base_sha":      mr.DiffRefs.BaseSHA,
+		"start_sha":     mr.DiffRefs.StartSHA,
+		"head_sha":      mr.DiffRefs.HeadSHA,
+		"new_path":      position.NewPath,
+		"old_path":      oldPath,
+		"new_line":      position.NewLine,
+	}
+
+	if position.OldLine > 0 {
+		requestPosition["old_line"] = position.OldLine
+	}
+
+	return g.client.postJSON(
+		fmt.Sprintf("%s/projects/%s/merge_requests/%d/discussions", apiURL, projectID, mrIID),
+		map[string]interface{}{
+			"body":     body,
+			"position": requestPosition,
+		},
+	)
+}
+
+// PositionOf returns the position of a line of the new version of the file, the old line is looked up
+// in the diff, as GitLab needs both of them for lines which were not changed
+func (g *GitLab) PositionOf(mrURL, filePath string, line int) (Position, error) {
+	diffs, err := g.diffs(mrURL)
+	if err != nil {
+		return Position{}, err
+	}
+
+	for _, d := range diffs {
+		if d.NewPath == filePath {
+			_, oldLine, _ := diffLine(d.Diff, line)
+			return Position{
+				NewPath: d.NewPath,
+				OldPath: d.OldPath,
+				NewLine: line,
+				OldLine: oldLine,
+			}, nil
+		}
+	}
+
+	return Position{NewPath: filePath, NewLine: line}, nil
+}
+
+// gitlabDiscussion is a discussion of a merge request, the notes of a diff discussion have a position
+type gitlabDiscussion struct {
+	ID    string `json:"id"`
+	Notes []struct {
+		ID     int64  `json:"id"`
+		Body   string `json:"body"`
+		System bool   `json:"system"`
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+		Position *struct {
+			NewPath string `json:"new_path"`
+			NewLine int    `json:"new_line"`
+		} `json:"position"`
+	} `json:"notes"`
+}
+
+// ReviewThreads implements ThreadReader, the threads are the discussions on the diff
+func (g *GitLab) ReviewThreads(mrURL string) ([]Thread, error) {
+	apiURL, projectID, mrIID, err := g.project(mrURL)
+	if err != nil {
+		return nil, err
+	}
+
+	discussions, err := gitlabPages[gitlabDiscussion](g.client, fmt.Sprintf("%s/projects/%s/merge_requests/%d/discussions", apiURL, projectID, mrIID))
+	if err != nil {
+		return nil, err
+	}
+
+	diffs, err := g.diffs(mrURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var threads []Thread
+	for _, discussion := range discussions {
+		if len(discussion.Notes) == 0 || discussion.Notes[0].Position == nil {
+			continue // not on the diff
+		}
+
+		position := discussion.Notes[0].Position
+		thread := Thread{
+			ID:       discussion.ID,
+			FilePath: position.NewPath,
+			Line:     position.NewLine,
+		}
+
+		for _, d := range diffs {
+			if d.NewPath == position.NewPath && position.NewLine > 0 {
+				thread.DiffHunk = hunkAt(d.Diff, position.NewLine)
+			}
+		}
+
+		for _, note := range discussion.Notes {
+			if note.System {
+				continue
+			}
+
+			thread.Comments = append(thread.Comments, ThreadComment{
+				ID:     strconv.FormatInt(note.ID, 10),
+				Author: note.Author.Username,
+				Body:   note.Body,
+			})
+		}
+
+		threads = append(threads, thread)
+	}
+
+	return threads, nil
+}
+
+// ReplyToThread adds a note to the discussion of the thread ID
+func (g *GitLab) ReplyToThread(mrURL, threadID, body string) error {
+	apiURL, projectID, mrIID, err := g.project(mrURL)
+	if err != nil {
+		return err
+	}
+
+	return g.client.postJSON(
+		fmt.Sprintf("%s/projects/%s/merge_requests/%d/discussions/%s/notes", apiURL, projectID, mrIID, url.PathEscape(threadID)),
+		map[string]string{"body": body},
+	)
+}
+
+// diffs fetches all the pages of the diffs of the merge request, once per run
+func (g *GitLab) diffs(mrURL string) ([]gitlabDiff, error) {
+	return gitlabDiffsCache.get(mrURL, func() ([]gitlabDiff, error) {
+		apiURL, projectID, mrIID, err := g.project(mrURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return gitlabPages[gitlabDiff](g.client, fmt.Sprintf("%s/projects/%s/merge_requests/%d/diffs", apiURL, projectID, mrIID))
+	})
+}
+
+// gitlabPages fetches all the pages of a GitLab list, following the X-Next-Page header
+func gitlabPages[T any](c restClient, requestURL string) ([]T, error) {
+	var items []T
+	for page := "1"; page != ""; {
+		var pageItems []T
+		header, err := c.getJSON(fmt.Sprintf("%s?per_page=%d&page=%s", requestURL, gitlabPerPage, page), &pageItems)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, pageItems...)
+		page = header.Get("X-Next-Page")
+	}
+
+	return items, nil
+}
+
+// project returns the API URL, the URL encoded project path and the MR IID of the MR URL
+func (g *GitLab) project(mrURL string) (string, string, int, error) {
+	hostURL, projectPath, mrIID, err := git.GetMRInfo(mrURL)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	apiURL := g.env.GitlabAPIURL()
+	if apiURL == "" {
+		apiURL = hostURL + "/api/v4"
+	}
+
+	return apiURL, url.PathEscape(projectPath), mrIID, nil
+}
+
+// gitlabStatus maps the flags of the GitLab diff to the GitHub file status names
+func gitlabStatus(d gitlabDiff) string {
+	switch {
+	case d.NewFile:
+		return "added"
+	case d.DeletedFile:
+		return "removed"
+	case d.RenamedFile:
+		return "renamed"
+	default:
+		return "modified"
+	}
+}
@@ -0,0 +1,168 @@
+package pr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/env"
+)
+
+const gitlabTestProject = "/api/v4/projects/group%2Fproject/merge_requests/7"
+
+// gitlabTestDiffs are the diffs of the merge request in two pages, main.go has an added line 2,
+// lib/new.go is renamed from lib/old.go with a changed line 10
+var gitlabTestDiffs = [][]gitlabDiff{
+	{
+		{OldPath: "main.go", NewPath: "main.go", Diff: "@@ -1,3 +1,4 @@\n package main\n+import \"fmt\"\n \n func main() {}\n"},
+	},
+	{
+		{OldPath: "lib/old.go", NewPath: "lib/new.go", RenamedFile: true, Diff: "@@ -10,2 +10,2 @@\n-a := 1\n+a := 2\n return a\n"},
+		{OldPath: "gone.go", NewPath: "gone.go", DeletedFile: true, Diff: "@@ -1 +0,0 @@\n-package gone\n"},
+	},
+}
+
+// newTestGitLab serves a merge request on an httptest server, and returns the client with the URL of the merge request.
+// The bodies of the created discussions are sent to the discussions channel
+func newTestGitLab(t *testing.T, discussions chan<- map[string]any) (*GitLab, string) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			t.Errorf("got token %q on %s", r.Header.Get("PRIVATE-TOKEN"), r.URL)
+		}
+
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET " + gitlabTestProject:
+			w.Write([]byte(`{"iid":7,"diff_refs":{"base_sha":"base1","start_sha":"start1","head_sha":"head1"}}`))
+		case "GET " + gitlabTestProject + "/diffs":
+			if r.URL.Query().Get("per_page") != "100" {
+				t.Errorf("got per_page %q", r.URL.Query().Get("per_page"))
+			}
+
+			page := gitlabTestDiffs[0]
+			if r.URL.Query().Get("page") == "2" {
+				page = gitlabTestDiffs[1]
+			} else {
+				w.Header().Set("X-Next-Page", "2")
+			}
+			json.NewEncoder(w).Encode(page)
+		case "POST " + gitlabTestProject + "/discussions":
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("invalid discussion body: %s", err)
+			}
+			discussions <- body
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"d1"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv(env.EnvGitlabToken, "secret")
+	t.Setenv(env.EnvGitlabAPIURL, "")
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The API is on the host of the MR URL, every test server has its own, so the run caches do not mix
+	return NewGitLab(envManager), server.URL + "/group/project/-/merge_requests/7"
+}
+
+func TestGitLabReadsAllPagesOfTheDiffs(t *testing.T) {
+	client, mrURL := newTestGitLab(t, nil)
+
+	diffs, err := client.GetPRFileDiffs(mrURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diffs) != 3 || diffs[0].Filename != "main.go" || diffs[1].Status != "renamed" || diffs[2].Status != "removed" {
+		t.Errorf("got diffs %+v", diffs)
+	}
+
+	files, err := client.GetPRFiles(mrURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 || files[0] != "main.go" || files[1] != "lib/new.go" {
+		t.Errorf("got files %v, want the deleted file left out", files)
+	}
+}
+
+func TestGitLabPositionOfLooksUpTheOldLine(t *testing.T) {
+	client, mrURL := newTestGitLab(t, nil)
+
+	tests := []struct {
+		filePath string
+		line     int
+		want     Position
+	}{
+		{"main.go", 2, Position{NewPath: "main.go", OldPath: "main.go", NewLine: 2}},             // Added
+		{"main.go", 4, Position{NewPath: "main.go", OldPath: "main.go", NewLine: 4, OldLine: 3}}, // Unchanged
+		{"lib/new.go", 11, Position{NewPath: "lib/new.go", OldPath: "lib/old.go", NewLine: 11, OldLine: 11}},
+		{"other.go", 5, Position{NewPath: "other.go", NewLine: 5}}, // Not in the diff
+	}
+
+	for _, test := range tests {
+		got, err := client.PositionOf(mrURL, test.filePath, test.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != test.want {
+			t.Errorf("got position %+v of %s:%d, want %+v", got, test.filePath, test.line, test.want)
+		}
+	}
+}
+
+func TestGitLabCreateDiscussionSendsTheDiffRefs(t *testing.T) {
+	discussions := make(chan map[string]any, 2)
+	client, mrURL := newTestGitLab(t, discussions)
+
+	added := Position{NewPath: "lib/new.go", OldPath: "lib/old.go", NewLine: 10}
+	if err := client.CreateDiscussion(mrURL, "**major** off by one", added); err != nil {
+		t.Fatal(err)
+	}
+
+	unchanged := Position{NewPath: "main.go", NewLine: 4, OldLine: 3}
+	if err := client.CreateDiscussion(mrURL, "nit", unchanged); err != nil {
+		t.Fatal(err)
+	}
+
+	body := <-discussions
+	if body["body"] != "**major** off by one" {
+		t.Errorf("got body %v", body["body"])
+	}
+
+	position, _ := body["position"].(map[string]any)
+	want := map[string]any{
+		"position_type": "text",
+		"base_sha":      "base1",
+		"start_sha":     "start1",
+		"head_sha":      "head1",
+		"new_path":      "lib/new.go",
+		"old_path":      "lib/old.go",
+		"new_line":      float64(10),
+	}
+	for key, value := range want {
+		if position[key] != value {
+			t.Errorf("got %s %v, want %v", key, position[key], value)
+		}
+	}
+
+	if _, ok := position["old_line"]; ok {
+		t.Errorf("got old_line %v on an added line", position["old_line"])
+	}
+
+	position, _ = (<-discussions)["position"].(map[string]any)
+	if position["old_path"] != "main.go" || position["new_line"] != float64(4) || position["old_line"] != float64(3) {
+		t.Errorf("got position %v of an unchanged line", position)
+	}
+}
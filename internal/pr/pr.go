@@ -2,7 +2,22 @@
 package pr
 
 import (
+	"fmt"
+
+	cmdinterpreter "github.com/olbrichattila/qreview/internal/cmd-interpreter"
 	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/git"
+)
+
+// Kind is the forge hosting the pull request
+type Kind string
+
+const (
+	KindGitHub          Kind = "github"
+	KindGitLab          Kind = "gitlab"
+	KindBitbucket       Kind = "bitbucket"        // Bitbucket Cloud
+	KindBitbucketServer Kind = "bitbucket-server" // Bitbucket Server and Data Center
+	KindGitea           Kind = "gitea"            // Gitea and Forgejo
 )
 
 type PullRequest interface {
@@ -11,8 +26,96 @@ type PullRequest interface {
 	GetPRFileDiffs(prURL string) ([]FileDiff, error)
 }
 
-func New(env env.EnvironmentManager) PullRequest {
-	// Currently it supports only github, for others please add .env variable
-	// and switch case between them here
-	return newGitHub(env)
+func New(env env.EnvironmentManager, kind Kind) PullRequest {
+	switch kind {
+	case KindGitLab:
+		return NewGitLab(env)
+	case KindBitbucket:
+		return NewBitbucket(env)
+	case KindBitbucketServer:
+		return NewBitbucketServer(env)
+	case KindGitea:
+		return NewGitea(env)
+	default:
+		return NewGitHub(env)
+	}
+}
+
+// URLFromCommandLine returns the PR URL given by -pr, -githubPr or -gitlabMr, false if a local review was asked
+func URLFromCommandLine() (string, bool) {
+	for _, flag := range []string{cmdinterpreter.FlagPR, cmdinterpreter.FlagGithubPR, cmdinterpreter.FlagGitlabMR} {
+		if prURL, err := cmdinterpreter.Flag(flag); err == nil {
+			return prURL, true
+		}
+	}
+
+	return "", false
+}
+
+// FromCommandLine returns the forge and the URL of the PR to be reviewed, false if a local review was asked.
+// -githubPr and -gitlabMr name the forge, the forge of -pr is detected
+func FromCommandLine(env env.EnvironmentManager) (Kind, string, bool, error) {
+	if prURL, err := cmdinterpreter.Flag(cmdinterpreter.FlagGithubPR); err == nil {
+		return KindGitHub, prURL, true, nil
+	}
+
+	if mrURL, err := cmdinterpreter.Flag(cmdinterpreter.FlagGitlabMR); err == nil {
+		return KindGitLab, mrURL, true, nil
+	}
+
+	prURL, err := cmdinterpreter.Flag(cmdinterpreter.FlagPR)
+	if err != nil {
+		return "", "", false, nil
+	}
+
+	kind, err := DetectKind(env, prURL)
+	return kind, prURL, true, err
+}
+
+// DetectKind returns the forge of the PR URL by its pattern, PR_PROVIDER overrides it for hosts the pattern is ambiguous for
+func DetectKind(env env.EnvironmentManager, prURL string) (Kind, error) {
+	if provider := env.PRProvider(); provider != "" {
+		switch Kind(provider) {
+		case KindGitHub, KindGitLab, KindBitbucket, KindBitbucketServer, KindGitea:
+			return Kind(provider), nil
+		case "forgejo":
+			return KindGitea, nil
+		default:
+			return "", fmt.Errorf("invalid PR_PROVIDER %s, should be github, gitlab, bitbucket, bitbucket-server or gitea", provider)
+		}
+	}
+
+	switch {
+	case git.IsValidGitHubPRURL(prURL):
+		return KindGitHub, nil
+	case git.IsValidGitLabMRURL(prURL):
+		return KindGitLab, nil
+	case git.IsValidBitbucketServerPRURL(prURL):
+		return KindBitbucketServer, nil
+	case git.IsValidBitbucketCloudPRURL(prURL):
+		return KindBitbucket, nil
+	case git.IsValidGiteaPRURL(prURL):
+		return KindGitea, nil
+	default:
+		return "", fmt.Errorf("cannot tell the forge of the PR URL %s, set PR_PROVIDER", prURL)
+	}
+}
+
+// ValidateURL checks if the PR URL looks like a pull request of the forge
+func ValidateURL(kind Kind, prURL string) error {
+	var err error
+	switch kind {
+	case KindGitLab:
+		_, _, _, err = git.GetMRInfo(prURL)
+	case KindBitbucket:
+		_, err = git.GetBitbucketCloudPRInfo(prURL)
+	case KindBitbucketServer:
+		_, err = git.GetBitbucketServerPRInfo(prURL)
+	case KindGitea:
+		_, err = git.GetGiteaPRInfo(prURL)
+	default:
+		_, _, _, err = git.GetPRInfo(prURL)
+	}
+
+	return err
 }
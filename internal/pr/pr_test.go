@@ -0,0 +1,39 @@
+package pr
+
+import (
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/env"
+)
+
+func TestDetectKind(t *testing.T) {
+	tests := []struct {
+		provider string
+		prURL    string
+		want     Kind
+		wantErr  bool
+	}{
+		{"", "https://github.com/o/r/pull/1", KindGitHub, false},
+		{"", "https://gitlab.com/group/project/-/merge_requests/1", KindGitLab, false},
+		{"", "https://bitbucket.org/ws/repo/pull-requests/1", KindBitbucket, false},
+		{"", "https://bitbucket.example.com/projects/KEY/repos/repo/pull-requests/1", KindBitbucketServer, false},
+		{"", "https://codeberg.org/owner/repo/pulls/1", KindGitea, false},
+		{"", "https://example.com/o/r/issues/1", "", true},
+		{"forgejo", "https://git.example.com/o/r/pulls/1", KindGitea, false},
+		{"gitlab", "https://git.example.com/o/r/pull/1", KindGitLab, false}, // The provider wins over the pattern
+		{"svn", "https://github.com/o/r/pull/1", "", true},
+	}
+
+	for _, test := range tests {
+		t.Setenv(env.EnvPRProvider, test.provider)
+		envManager, err := env.NewDotEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := DetectKind(envManager, test.prURL)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("got %q, %v for %s with PR_PROVIDER %q, want %q", got, err, test.prURL, test.provider, test.want)
+		}
+	}
+}
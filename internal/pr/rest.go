@@ -0,0 +1,295 @@
+package pr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	restMaxAttempts         = 3
+	restMaxRateLimitWait    = 2 * time.Minute
+	restSecondaryLimitDelay = time.Minute // GitHub asks to wait at least a minute if a secondary rate limit has no Retry-After
+)
+
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// APIError is returned when the forge API does not answer with 2xx
+type APIError struct {
+	Forge      string
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	message := fmt.Sprintf("%s API returned %s: %s", e.Forge, e.Status, e.Message)
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return message + ", check the token"
+	case http.StatusNotFound:
+		return message + ", check the URL and that the token can read the repository"
+	default:
+		return message
+	}
+}
+
+// restClient sends the requests of a forge API, authorized the way the forge expects it.
+// It waits when the rate limit of the API is used up, and retries the throttled requests
+type restClient struct {
+	name       string // Name of the forge in the error messages
+	authorize  func(req *http.Request)
+	httpClient *http.Client
+	limit      *rateLimit
+}
+
+// newRESTClient creates the client of a forge API, a request including the reading of the body fails after the timeout
+func newRESTClient(name string, timeout time.Duration, authorize func(req *http.Request)) restClient {
+	return restClient{
+		name:       name,
+		authorize:  authorize,
+		httpClient: &http.Client{Timeout: timeout},
+		limit:      &rateLimit{},
+	}
+}
+
+// do sends the request, and returns the body and the headers of a successful response.
+// Cancelling the context stops the request and the waiting for the rate limit
+func (c restClient) do(ctx context.Context, method, requestURL string, body []byte) ([]byte, http.Header, error) {
+	for attempt := 1; ; attempt++ {
+		if err := c.limit.wait(ctx, c.name); err != nil {
+			return nil, nil, err
+		}
+
+		content, resp, err := c.send(ctx, method, requestURL, body)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		c.limit.update(resp.Header)
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return content, resp.Header, nil
+		}
+
+		apiErr := &APIError{
+			Forge:      c.name,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Message:    errorMessage(content),
+		}
+
+		wait, throttled := throttleDelay(resp, apiErr.Message)
+		if !throttled || attempt == restMaxAttempts || wait > restMaxRateLimitWait {
+			return nil, nil, apiErr
+		}
+
+		fmt.Printf("%s API rate limit hit (attempt %d/%d), retrying in %s\n", c.name, attempt, restMaxAttempts, wait.Round(time.Second))
+		if err := sleep(ctx, wait); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+func (c restClient) send(ctx context.Context, method, requestURL string, body []byte) ([]byte, *http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.authorize(req)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return content, resp, nil
+}
+
+// getJSON fetches and decodes a JSON response
+func (c restClient) getJSON(requestURL string, value any) (http.Header, error) {
+	content, header, err := c.do(context.Background(), http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, value); err != nil {
+		return nil, fmt.Errorf("could not parse %s API response: %w", c.name, err)
+	}
+
+	return header, nil
+}
+
+// postJSON sends the value as a JSON body
+func (c restClient) postJSON(requestURL string, value any) error {
+	_, err := c.sendJSON(http.MethodPost, requestURL, value)
+	return err
+}
+
+// sendJSON sends the value as a JSON body with the method, and returns the response body
+func (c restClient) sendJSON(method, requestURL string, value any) ([]byte, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	content, _, err := c.do(context.Background(), method, requestURL, body)
+	return content, err
+}
+
+// getPages fetches all the pages of a JSON list, following the next link of the Link header
+func getPages[T any](c restClient, requestURL string) ([]T, error) {
+	var items []T
+	for next := requestURL; next != ""; {
+		var page []T
+		header, err := c.getJSON(next, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page...)
+		next = ""
+		if match := linkNextRegex.FindStringSubmatch(header.Get("Link")); match != nil {
+			next = match[1]
+		}
+	}
+
+	return items, nil
+}
+
+// rateLimit remembers when the quota of the API resets after the last request used it up
+type rateLimit struct {
+	mu      sync.Mutex
+	resetAt time.Time
+}
+
+// wait blocks until the quota resets or the context is done. A reset too far away is not waited for,
+// the request fails with the API error
+func (r *rateLimit) wait(ctx context.Context, name string) error {
+	r.mu.Lock()
+	wait := time.Until(r.resetAt)
+	r.mu.Unlock()
+
+	if wait <= 0 || wait > restMaxRateLimitWait {
+		return nil
+	}
+
+	fmt.Printf("%s API rate limit used up, waiting %s\n", name, wait.Round(time.Second))
+	return sleep(ctx, wait)
+}
+
+// sleep waits for the duration, or returns the error of the context if it is done earlier
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update reads the X-RateLimit headers of the response
+func (r *rateLimit) update(header http.Header) {
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.resetAt = time.Unix(reset, 0)
+	r.mu.Unlock()
+}
+
+// throttleDelay tells if the response was throttled and how long to wait before the next try
+func throttleDelay(resp *http.Response, message string) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return max(time.Until(time.Unix(reset, 0)), 0), true
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || strings.Contains(strings.ToLower(message), "rate limit") {
+		return restSecondaryLimitDelay, true
+	}
+
+	// other 403s are permission errors
+	return 0, false
+}
+
+// errorMessage returns the message field of a JSON error response, or the body as it is
+func errorMessage(content []byte) string {
+	var jsonErr struct {
+		Message string `json:"message"`
+	}
+
+	if err := json.Unmarshal(content, &jsonErr); err == nil && jsonErr.Message != "" {
+		return jsonErr.Message
+	}
+
+	return strings.TrimSpace(string(content))
+}
+
+// runCache keeps what is fetched once per run, like the diffs of a pull request, shared by the source and the commenter.
+// Concurrent reviews wait for the first fetch
+type runCache[T any] struct {
+	mu    sync.Mutex
+	items map[string]T
+}
+
+func (c *runCache[T]) get(key string, fetch func() (T, error)) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, ok := c.items[key]; ok {
+		return item, nil
+	}
+
+	item, err := fetch()
+	if err != nil {
+		return item, err
+	}
+
+	if c.items == nil {
+		c.items = map[string]T{}
+	}
+	c.items[key] = item
+
+	return item, nil
+}
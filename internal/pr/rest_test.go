@@ -0,0 +1,39 @@
+package pr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRESTClientTimesOut(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := newRESTClient("Test", 50*time.Millisecond, func(*http.Request) {})
+	if _, _, err := client.do(context.Background(), http.MethodGet, server.URL, nil); err == nil {
+		t.Fatal("got no error from a hanging server")
+	}
+}
+
+func TestRESTClientStopsWaitingForTheRateLimit(t *testing.T) {
+	client := newRESTClient("Test", time.Second, func(*http.Request) {})
+	client.limit.resetAt = time.Now().Add(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := client.do(ctx, http.MethodGet, "http://localhost", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want the context canceled", err)
+	}
+}
@@ -0,0 +1,22 @@
+package pr
+
+// Thread is a review discussion on a line of a file of the pull request
+type Thread struct {
+	ID       string // Identifies the thread when replying to it
+	FilePath string
+	Line     int             // Line of the new version of the file, 0 if the line is gone
+	DiffHunk string          // The hunk of the diff the thread is on, empty if it cannot be told
+	Comments []ThreadComment // In the order they were posted
+}
+
+// ThreadComment is a comment of a review thread
+type ThreadComment struct {
+	ID     string
+	Author string
+	Body   string
+}
+
+// ThreadReader is implemented by the pull requests of the forges whose review threads can be read
+type ThreadReader interface {
+	ReviewThreads(prURL string) ([]Thread, error)
+}
@@ -0,0 +1,135 @@
+// Package prcheck publishes the result of the review on the head commit of the PR, as a GitHub check run or commit status
+package prcheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+// Name is the name of the check run and the context of the commit status
+const Name = "qreview"
+
+// Conclusions of the check
+const (
+	ConclusionSuccess = "success" // No findings
+	ConclusionNeutral = "neutral" // Findings below the fail on severity, they do not block the merge
+	ConclusionFailure = "failure" // Findings at or above the fail on severity, or files which could not be reviewed
+)
+
+// Annotation is a finding of a definition on lines of a file of the PR
+type Annotation struct {
+	Definition string
+	FilePath   string
+	Finding    reviewparser.Finding
+}
+
+// Result is the outcome of the review run
+type Result struct {
+	Annotations []Annotation
+	Files       int    // Number of the reviewed files
+	Failures    int    // Number of the files or definitions which failed
+	FailOn      string // Findings of this severity or above block the merge, empty means never
+}
+
+// Blocking returns the number of findings at or above the fail on severity
+func (r Result) Blocking() int {
+	if r.FailOn == "" {
+		return 0
+	}
+
+	blocking := 0
+	for _, annotation := range r.Annotations {
+		if annotation.Finding.AtLeast(r.FailOn) {
+			blocking++
+		}
+	}
+
+	return blocking
+}
+
+// Conclusion derives the conclusion of the check from the findings and the failures
+func (r Result) Conclusion() string {
+	switch {
+	case r.Failures > 0 || r.Blocking() > 0:
+		return ConclusionFailure
+	case len(r.Annotations) > 0:
+		return ConclusionNeutral
+	default:
+		return ConclusionSuccess
+	}
+}
+
+// Title is the one line outcome, it is the description of a commit status
+func (r Result) Title() string {
+	parts := []string{fmt.Sprintf("%d findings on %d files", len(r.Annotations), r.Files)}
+	if blocking := r.Blocking(); blocking > 0 {
+		parts = append(parts, fmt.Sprintf("%d of severity %s or above", blocking, r.FailOn))
+	}
+
+	if r.Failures > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", r.Failures))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Summary is the markdown overview of the findings by severity and definition
+func (r Result) Summary() string {
+	bySeverity := map[string]int{}
+	byDefinition := map[string]int{}
+	var definitions []string
+	for _, annotation := range r.Annotations {
+		bySeverity[annotation.Finding.Severity]++
+		if byDefinition[annotation.Definition] == 0 {
+			definitions = append(definitions, annotation.Definition)
+		}
+		byDefinition[annotation.Definition]++
+	}
+
+	var summary strings.Builder
+	summary.WriteString(fmt.Sprintf("### Automated review\n\n%s.\n", r.Title()))
+	if len(r.Annotations) > 0 {
+		summary.WriteString("\n| Severity | Findings |\n| --- | --- |\n")
+		for i := len(reviewparser.Severities) - 1; i >= 0; i-- {
+			if count := bySeverity[reviewparser.Severities[i]]; count > 0 {
+				summary.WriteString(fmt.Sprintf("| %s | %d |\n", reviewparser.Severities[i], count))
+			}
+		}
+		if count := bySeverity[""]; count > 0 {
+			summary.WriteString(fmt.Sprintf("| untagged | %d |\n", count))
+		}
+
+		summary.WriteString("\n| Definition | Findings |\n| --- | --- |\n")
+		for _, definition := range definitions {
+			summary.WriteString(fmt.Sprintf("| %s | %d |\n", definition, byDefinition[definition]))
+		}
+	}
+
+	if r.FailOn != "" {
+		summary.WriteString(fmt.Sprintf("\nFindings of severity %s or above fail the check.\n", r.FailOn))
+	}
+
+	if r.Failures > 0 {
+		summary.WriteString("\nSome files could not be reviewed, see the failures of the run.\n")
+	}
+
+	return summary.String()
+}
+
+// Publisher publishes the result of the review on the PR
+type Publisher interface {
+	Publish(prURL string, result Result) error
+}
+
+func New(env env.EnvironmentManager, kind pr.Kind) (Publisher, error) {
+	switch kind {
+	case pr.KindGitHub:
+		return newGitHub(env)
+	default:
+		return nil, fmt.Errorf("check runs are only supported on GitHub, not on %s", kind)
+	}
+}
@@ -0,0 +1,105 @@
+package prcheck
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
+)
+
+// statusMaxDescription is the limit of the description of a commit status
+const statusMaxDescription = 140
+
+func newGitHub(env env.EnvironmentManager) (Publisher, error) {
+	if env == nil || env.GithubToken() == "" {
+		return nil, fmt.Errorf("please provide github token in your environment: `GITHUB_TOKEN`")
+	}
+
+	return &github{client: pr.NewGitHub(env)}, nil
+}
+
+type github struct {
+	client *pr.GitHub
+}
+
+// Publish implements Publisher. Only GitHub Apps, like the token of GitHub Actions, can create check runs,
+// the result is a commit status for other tokens
+func (g *github) Publish(prURL string, result Result) error {
+	conclusion := result.Conclusion()
+	err := g.client.CreateCheckRun(prURL, Name, conclusion, pr.CheckOutput{
+		Title:       result.Title(),
+		Summary:     result.Summary(),
+		Annotations: checkAnnotations(result),
+	})
+
+	var apiErr *pr.APIError
+	if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusForbidden && apiErr.StatusCode != http.StatusNotFound) {
+		return err
+	}
+
+	fmt.Printf("cannot create a check run, setting a commit status instead. %s\n", err)
+	state := "success"
+	if conclusion == ConclusionFailure {
+		state = "failure"
+	}
+
+	description := result.Title()
+	if len(description) > statusMaxDescription {
+		description = description[:statusMaxDescription-3] + "..."
+	}
+
+	return g.client.CreateStatus(prURL, state, Name, description)
+}
+
+// checkAnnotations returns an annotation per finding, the findings at or above the fail on severity are failures
+func checkAnnotations(result Result) []pr.CheckAnnotation {
+	annotations := make([]pr.CheckAnnotation, 0, len(result.Annotations))
+	for _, annotation := range result.Annotations {
+		finding := annotation.Finding
+		startLine := max(finding.StartLine, 1)
+
+		level := "notice"
+		switch {
+		case result.FailOn != "" && finding.AtLeast(result.FailOn):
+			level = "failure"
+		case finding.AtLeast("minor"):
+			level = "warning"
+		}
+
+		message := finding.Message
+		if finding.Suggestion != "" {
+			message += "\n\nSuggestion: " + finding.Suggestion
+		}
+
+		annotations = append(annotations, pr.CheckAnnotation{
+			Path:            annotation.FilePath,
+			StartLine:       startLine,
+			EndLine:         max(finding.EndLine, startLine),
+			AnnotationLevel: level,
+			Title:           annotationTitle(annotation),
+			Message:         message,
+			RawDetails:      finding.Replacement,
+		})
+	}
+
+	return annotations
+}
+
+// annotationTitle names the definition, the severity and the category of the finding, like `security: major injection`
+func annotationTitle(annotation Annotation) string {
+	var tags []string
+	for _, tag := range []string{annotation.Finding.Severity, annotation.Finding.Category} {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) == 0 {
+		return annotation.Definition
+	}
+
+	return annotation.Definition + ": " + strings.Join(tags, " ")
+}
@@ -0,0 +1,61 @@
+package prcomment
+
+import (
+	"fmt"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
+)
+
+// lineCommenter is a forge client which can post a comment on a line of a file of the PR
+type lineCommenter interface {
+	CreateComment(prURL, filePath string, line int, body string) error
+}
+
+func newBitbucket(env env.EnvironmentManager, kind pr.Kind) (Commenter, error) {
+	if env == nil || env.BitbucketToken() == "" {
+		return nil, fmt.Errorf("please provide bitbucket token in your environment: `BITBUCKET_TOKEN`")
+	}
+
+	if kind == pr.KindBitbucketServer {
+		return &inline{client: pr.NewBitbucketServer(env)}, nil
+	}
+
+	return &inline{client: pr.NewBitbucket(env)}, nil
+}
+
+func newGitea(env env.EnvironmentManager) (Commenter, error) {
+	if env == nil || env.GiteaToken() == "" {
+		return nil, fmt.Errorf("please provide gitea token in your environment: `GITEA_TOKEN`")
+	}
+
+	return &inline{client: pr.NewGitea(env)}, nil
+}
+
+// inline comments through the API of forges which anchor comments by the path and the line of the new file
+type inline struct {
+	client lineCommenter
+}
+
+// Comment implements Commenter.
+func (i *inline) Comment(prURL string, comment Comment) error {
+	lineNumber := comment.Line
+	if lineNumber == 0 {
+		lineNumber = 1
+	}
+
+	if err := i.client.CreateComment(prURL, comment.FilePath, lineNumber, comment.Body()); err != nil {
+		// Skip error not to break PR
+		fmt.Printf("failed to post comment. %s: File: %s\n", err, comment.FilePath)
+	}
+
+	return nil
+}
+
+// Reviewed implements Commenter.
+func (i *inline) Reviewed(_, _ string) {}
+
+// Submit implements Commenter, the comments are posted right away
+func (i *inline) Submit(_, _ string) error {
+	return nil
+}
@@ -1,14 +1,60 @@
-// Package prcomment comments on gitHub, Gitlab.. what is implemented
+// Package prcomment comments on GitHub, GitLab, Bitbucket and Gitea
 package prcomment
 
-import "github.com/olbrichattila/qreview/internal/env"
+import (
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
 
+// Comment is a finding to be posted on a line of a file of the PR
+type Comment struct {
+	Definition string // Name of the review definition which made the finding
+	FilePath   string
+	StartLine  int // First line of a multi-line comment, 0 for a single line
+	Line       int // The line of the comment, the last line of a multi-line comment
+	Finding    reviewparser.Finding
+	Reviewed   map[int]bool // Lines of the file the model saw, a suggestion can only replace these
+	occurrence int          // Numbers the findings with the same fingerprint on other lines of the file, 0 for the first
+}
+
+// Replaceable tells if the replacement of the finding can be suggested for the lines from start to end. The model
+// does not see the blank lines, so a replacement of a range with a blank or unknown line would delete that line
+func (c Comment) Replaceable(start, end int) bool {
+	for line := start; line <= end; line++ {
+		if !c.Reviewed[line] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Body returns the markdown text of the comment
+func (c Comment) Body() string {
+	return c.Finding.Markdown()
+}
+
+// Commenter posts the comments on the PR. Only the GitHub commenter recognises its earlier comments and the duplicates
+// of the run, GitLab, Bitbucket and Gitea post every comment, also on a re-run
 type Commenter interface {
-	Comment(prURL, filePath string, comment string, lineNumber int) error
+	Comment(prURL string, comment Comment) error
+	// Reviewed records that the file was reviewed by the definition in this run, even if there is nothing to comment
+	Reviewed(definition, filePath string)
+	// Submit publishes the comments collected during the run, the review requests changes if a finding is
+	// at requestChangesOn severity or above. Commenters posting each comment right away do nothing
+	Submit(prURL, requestChangesOn string) error
 }
 
-func New(env env.EnvironmentManager) (Commenter, error) {
-	// Currently it supports only github, for others please add .env variable
-	// and switch case between them here
-	return newGitHub(env)
+func New(env env.EnvironmentManager, kind pr.Kind) (Commenter, error) {
+	switch kind {
+	case pr.KindGitLab:
+		return newGitLab(env)
+	case pr.KindBitbucket, pr.KindBitbucketServer:
+		return newBitbucket(env, kind)
+	case pr.KindGitea:
+		return newGitea(env)
+	default:
+		return newGitHub(env)
+	}
 }
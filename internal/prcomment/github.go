@@ -1,117 +1,314 @@
 package prcomment
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 
+	cmdinterpreter "github.com/olbrichattila/qreview/internal/cmd-interpreter"
 	"github.com/olbrichattila/qreview/internal/env"
-	"github.com/olbrichattila/qreview/internal/git"
+	"github.com/olbrichattila/qreview/internal/pr"
+	"github.com/olbrichattila/qreview/internal/reviewparser"
 )
 
+// gitHubMaxBodyLength is the limit of the review body of the GitHub API
+const gitHubMaxBodyLength = 65536
+
 func newGitHub(env env.EnvironmentManager) (Commenter, error) {
 	if env == nil {
 		return nil, fmt.Errorf("please provide github token in your environment: `GITHUB_TOKEN`")
 	}
 
 	return &github{
-		env: env,
+		client:           pr.NewGitHub(env),
+		minimizeOutdated: env.MinimizeOutdatedComments() || cmdinterpreter.HasFlag(cmdinterpreter.FlagMinimize),
+		reviewed:         map[string]bool{},
 	}, nil
 }
 
+// github collects the comments of the run, and submits them as a single pull request review.
+// Comments of earlier runs are recognised by their hidden marker, they are not posted again
 type github struct {
-	env env.EnvironmentManager
+	client           *pr.GitHub
+	minimizeOutdated bool
+	mu               sync.Mutex
+	comments         []Comment
+	reviewed         map[string]bool // definition and file pairs of the run
 }
 
 // Comment implements Commenter.
-func (g *github) Comment(prURL, filePath string, comment string, lineNumber int) error {
-	githubToken := g.env.GithubToken()
-	owner, repo, prNumber, err := git.GetPRInfo(prURL)
-	if err != nil {
-		return err
+func (g *github) Comment(_ string, comment Comment) error {
+	if comment.Line == 0 {
+		comment.Line = 1
 	}
 
-	commitSHA, err := g.getPRHeadSHA(githubToken, owner, repo, prNumber)
-	if err != nil {
-		return err
+	g.mu.Lock()
+	g.comments = append(g.comments, comment)
+	g.mu.Unlock()
+
+	return nil
+}
+
+// Reviewed implements Commenter.
+func (g *github) Reviewed(definition, filePath string) {
+	g.mu.Lock()
+	g.reviewed[definition+"\x00"+filePath] = true
+	g.mu.Unlock()
+}
+
+// Submit implements Commenter. Comments already on the PR are skipped, or updated if their text changed.
+// Comments on lines outside of the diff cannot be anchored, they are listed in the review body.
+// Failed updates do not stop the review, they are returned after it is submitted
+func (g *github) Submit(prURL, requestChangesOn string) error {
+	g.mu.Lock()
+	comments := numberOccurrences(g.comments)
+	g.comments = nil
+	g.mu.Unlock()
+
+	if len(comments) == 0 && !g.minimizeOutdated {
+		return nil
 	}
 
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/comments", owner, repo, prNumber)
+	existing, earlierBodies := g.existing(prURL)
+
+	event := pr.ReviewEventComment
+	current := map[string]bool{}
+	var inlines []pr.ReviewComment
+	var unanchored []Comment
+	var unchanged, updated int
+	var updateErrs []error
+	for _, comment := range comments {
+		if requestChangesOn != "" && comment.Finding.AtLeast(requestChangesOn) {
+			event = pr.ReviewEventRequestChanges
+		}
+
+		mark := comment.marker()
+		current[mark.fingerprint] = true
+
+		inline, suggested, err := g.inlineComment(prURL, comment)
+		if err != nil {
+			return err
+		}
 
-	if lineNumber == 0 {
-		lineNumber = 1
+		body := inline.Body + "\n\n" + mark.String()
+		if earlier, ok := existing[mark.fingerprint]; ok {
+			if earlier.Body == body {
+				unchanged++
+				continue
+			}
+
+			if err := g.client.UpdateReviewComment(prURL, earlier.ID, body); err != nil {
+				updateErrs = append(updateErrs, fmt.Errorf("failed to update the comment on %s:%d: %w", comment.FilePath, comment.Line, err))
+				continue
+			}
+
+			updated++
+			continue
+		}
+
+		inDiff := suggested
+		if !suggested {
+			if inDiff, err = g.client.InDiff(prURL, comment.FilePath, comment.Line); err != nil {
+				return err
+			}
+		}
+
+		if !inDiff {
+			if earlierBodies[mark.fingerprint] {
+				unchanged++
+				continue
+			}
+
+			unanchored = append(unanchored, comment)
+			continue
+		}
+
+		inline.Body = body
+		inlines = append(inlines, inline)
 	}
 
-	body := map[string]interface{}{
-		"body":      comment,
-		"commit_id": commitSHA,
-		"path":      filePath,
-		"line":      lineNumber,
-		"side":      "RIGHT",
+	g.minimize(prURL, existing, current)
+
+	fmt.Printf("PR review: %d new inline comments, %d in the review body, %d updated, %d already posted\n", len(inlines), len(unanchored), updated, unchanged)
+	updateErr := errors.Join(updateErrs...)
+	if len(inlines) == 0 && len(unanchored) == 0 {
+		return updateErr
 	}
 
-	jsonBody, err := json.Marshal(body)
-	if err != nil {
-		return err
+	body := reviewBody(comments, unanchored)
+	err := g.client.CreateReview(prURL, body, event, inlines)
+
+	// The author of the PR cannot request changes on it, the review is posted as a comment then
+	var apiErr *pr.APIError
+	if event == pr.ReviewEventRequestChanges && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity {
+		err = g.client.CreateReview(prURL, body, pr.ReviewEventComment, inlines)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return err
+		// Skip error not to break PR
+		fmt.Printf("failed to submit review. %s\n", err)
 	}
 
-	req.Header.Set("Authorization", "token "+githubToken)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("Content-Type", "application/json")
+	return updateErr
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+// inlineComment returns the inline comment of the finding, spanning its lines if they are in one hunk, with its replacement of the finding as a suggested change spanning its lines.
+// GitHub can only apply a suggestion within a hunk, and only lines the model saw can be replaced,
+// otherwise the replacement stays a plain code block on the comment line
+func (g *github) inlineComment(prURL string, comment Comment) (pr.ReviewComment, bool, error) {
+	inline := pr.ReviewComment{
+		Path: comment.FilePath,
+		Line: comment.Line,
+		Body: comment.Body(),
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return nil
+	if comment.StartLine > 0 && comment.StartLine < comment.Line {
+		inHunk, err := g.client.InOneHunk(prURL, comment.FilePath, comment.StartLine, comment.Line)
+		if err != nil {
+			return inline, false, err
+		}
+
+		if inHunk {
+			inline.StartLine = comment.StartLine
+		}
 	}
 
-	// Skip error not to break PR
-	fmt.Printf("failed to post comment. Status: %s: File: %s\n", resp.Status, filePath)
-	return nil
+	finding := comment.Finding
+	endLine := max(finding.EndLine, finding.StartLine)
+	if finding.Replacement == "" || finding.StartLine < 1 || !comment.Replaceable(finding.StartLine, endLine) {
+		return inline, false, nil
+	}
+
+	inHunk, err := g.client.InOneHunk(prURL, comment.FilePath, finding.StartLine, endLine)
+	if err != nil || !inHunk {
+		return inline, false, err
+	}
+
+	replacement := finding.Replacement
+	finding.Replacement = ""
+	inline.Body = finding.Markdown() + "\n\n" + reviewparser.CodeBlock("suggestion", replacement)
+	inline.Line = endLine
+	inline.StartLine = 0
+	if endLine > finding.StartLine {
+		inline.StartLine = finding.StartLine
+	}
+
+	return inline, true, nil
 }
 
-// getPRHeadSHA fetches the head commit SHA of a GitHub PR
-func (g *github) getPRHeadSHA(token, owner, repo string, prNumber int) (string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d", owner, repo, prNumber)
+// existing returns the inline comments of earlier runs by fingerprint, and the fingerprints listed in earlier review bodies.
+// If they cannot be listed, the comments are posted as if there were none
+func (g *github) existing(prURL string) (map[string]pr.PRComment, map[string]bool) {
+	existing := map[string]pr.PRComment{}
+	earlierBodies := map[string]bool{}
 
-	req, err := http.NewRequest("GET", url, nil)
+	comments, err := g.client.ReviewComments(prURL)
 	if err != nil {
-		return "", err
+		fmt.Printf("failed to list the PR comments, duplicates are not detected. %s\n", err)
+		return existing, earlierBodies
+	}
+
+	for _, comment := range comments {
+		for _, mark := range parseMarkers(comment.Body) {
+			existing[mark.fingerprint] = comment
+		}
 	}
-	req.Header.Set("Authorization", "token "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	bodies, err := g.client.ReviewBodies(prURL)
 	if err != nil {
-		return "", err
+		fmt.Printf("failed to list the PR reviews, duplicates are not detected. %s\n", err)
+		return existing, earlierBodies
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GitHub API returned %s", resp.Status)
+	for _, body := range bodies {
+		for _, mark := range parseMarkers(body) {
+			earlierBodies[mark.fingerprint] = true
+		}
 	}
 
-	var result struct {
-		Head struct {
-			SHA string `json:"sha"`
-		} `json:"head"`
+	return existing, earlierBodies
+}
+
+// minimize hides the comments of earlier runs on the files reviewed again by the same definition, whose finding is gone
+func (g *github) minimize(prURL string, existing map[string]pr.PRComment, current map[string]bool) {
+	if !g.minimizeOutdated {
+		return
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+	fingerprints := make([]string, 0, len(existing))
+	for fingerprint := range existing {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Slice(fingerprints, func(i, j int) bool { return existing[fingerprints[i]].ID < existing[fingerprints[j]].ID })
+
+	for _, fingerprint := range fingerprints {
+		comment := existing[fingerprint]
+		if current[fingerprint] {
+			continue
+		}
+
+		for _, mark := range parseMarkers(comment.Body) {
+			if !g.reviewed[mark.definition+"\x00"+mark.filePath] {
+				continue
+			}
+
+			fmt.Printf("Minimizing outdated comment on PR File: %s, line %d\n", comment.Path, comment.Line)
+			if err := g.client.MinimizeComment(prURL, comment.NodeID); err != nil {
+				fmt.Printf("failed to minimize comment. %s: File: %s\n", err, comment.Path)
+			}
+		}
 	}
+}
+
+// reviewBody summarizes the comments by severity, and lists the ones which could not be anchored to the diff with their markers
+func reviewBody(comments, unanchored []Comment) string {
+	files := map[string]bool{}
+	bySeverity := map[string]int{}
+	for _, comment := range comments {
+		files[comment.FilePath] = true
+		if comment.Finding.Severity != "" {
+			bySeverity[comment.Finding.Severity]++
+		}
+	}
+
+	var body strings.Builder
+	body.WriteString(fmt.Sprintf("### Automated review\n\n%d comments on %d files", len(comments), len(files)))
+
+	var counts []string
+	for i := len(reviewparser.Severities) - 1; i >= 0; i-- {
+		severity := reviewparser.Severities[i]
+		if bySeverity[severity] > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", bySeverity[severity], severity))
+		}
+	}
+	if len(counts) > 0 {
+		body.WriteString(" (" + strings.Join(counts, ", ") + ")")
+	}
+
+	if len(unanchored) > 0 {
+		body.WriteString("\n\n#### Outside of the diff\n")
+		for _, comment := range unanchored {
+			location := fmt.Sprintf("%s:%d", comment.FilePath, comment.Line)
+			if comment.StartLine > 0 && comment.StartLine < comment.Line {
+				location = fmt.Sprintf("%s:%d-%d", comment.FilePath, comment.StartLine, comment.Line)
+			}
+			body.WriteString(fmt.Sprintf("\n**`%s`** %s\n%s\n", location, comment.Body(), comment.marker()))
+		}
+	}
+
+	text := body.String()
+	if len(text) > gitHubMaxBodyLength {
+		const truncated = "\n\n_The review body was truncated, see the report for all the comments._"
+		text = strings.ToValidUTF8(text[:gitHubMaxBodyLength-len(truncated)], "") + truncated
+	}
+
+	return text
+}
 
-	return result.Head.SHA, nil
+// Reply implements Replier.
+func (g *github) Reply(prURL string, thread pr.Thread, commentID, body string) error {
+	return g.client.ReplyToThread(prURL, thread.ID, body+"\n\n"+replyMarker(commentID))
 }
@@ -0,0 +1,132 @@
+package prcomment
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+// newTestGitHub returns the commenter talking to the handler, and the URL of a pull request on it.
+// The API is on the host of the PR URL, every test server has its own, so the run caches do not mix
+func newTestGitHub(t *testing.T, handler http.HandlerFunc) (*github, string) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv(env.EnvGithubToken, "secret")
+	t.Setenv(env.EnvGithubAPIURL, "")
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &github{client: pr.NewGitHub(envManager), reviewed: map[string]bool{}}, server.URL + "/o/r/pull/1"
+}
+
+// testPatch adds the lines 2 to 4 of main.go, line 3 is blank
+const testPatch = "@@ -1,3 +1,6 @@\n package main\n+import \"os\"\n+\n+var debug = os.Getenv(\"DEBUG\")\n func main() {\n }"
+
+func serveFiles(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet || r.URL.Path != "/api/v3/repos/o/r/pulls/1/files" {
+		return false
+	}
+
+	json.NewEncoder(w).Encode([]pr.FileDiff{{Filename: "main.go", Status: "modified", Patch: testPatch}})
+	return true
+}
+
+func TestInlineCommentSuggestsOnlyReviewedLines(t *testing.T) {
+	g, prURL := newTestGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		if !serveFiles(w, r) {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+
+	// The model saw the file without its blank line 3
+	reviewed := map[int]bool{1: true, 2: true, 4: true, 5: true, 6: true}
+	tests := []struct {
+		name      string
+		start     int
+		end       int
+		suggested bool
+	}{
+		{"range with the blank line", 2, 4, false},
+		{"range of reviewed lines", 4, 5, true},
+		{"line of the file not sent to the model", 7, 7, false},
+	}
+
+	for _, test := range tests {
+		comment := Comment{
+			FilePath: "main.go",
+			Line:     test.end,
+			Finding:  reviewparser.Finding{StartLine: test.start, EndLine: test.end, Message: "simplify", Replacement: "var debug = true"},
+			Reviewed: reviewed,
+		}
+
+		inline, suggested, err := g.inlineComment(prURL, comment)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if suggested != test.suggested || strings.Contains(inline.Body, "```suggestion") != test.suggested {
+			t.Errorf("%s: got suggested %t, body %q", test.name, suggested, inline.Body)
+		}
+
+		if !strings.Contains(inline.Body, "var debug = true") {
+			t.Errorf("%s: got body %q, want the replacement kept as code", test.name, inline.Body)
+		}
+	}
+}
+
+func TestSubmitReturnsFailedUpdates(t *testing.T) {
+	comments := []Comment{
+		{Definition: "review", FilePath: "main.go", Line: 2, Finding: reviewparser.Finding{StartLine: 2, Message: "unused import"}},
+		{Definition: "review", FilePath: "main.go", Line: 4, Finding: reviewparser.Finding{StartLine: 4, Message: "read the flag instead"}},
+	}
+
+	// Both findings were posted by an earlier run with another text, the update of the first one fails
+	var patched []string
+	g, prURL := newTestGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v3/repos/o/r/pulls/1/comments":
+			json.NewEncoder(w).Encode([]pr.PRComment{
+				{ID: 1, Path: "main.go", Line: 2, Body: "old\n\n" + comments[0].marker().String()},
+				{ID: 2, Path: "main.go", Line: 4, Body: "old\n\n" + comments[1].marker().String()},
+			})
+		case "GET /api/v3/repos/o/r/pulls/1/reviews":
+			w.Write([]byte(`[]`))
+		case "PATCH /api/v3/repos/o/r/pulls/comments/1":
+			patched = append(patched, "1")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		case "PATCH /api/v3/repos/o/r/pulls/comments/2":
+			patched = append(patched, "2")
+			w.Write([]byte(`{"id":2}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	for _, comment := range comments {
+		if err := g.Comment(prURL, comment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := g.Submit(prURL, "")
+	if err == nil || !strings.Contains(err.Error(), "main.go:2") || strings.Contains(err.Error(), "main.go:4") {
+		t.Errorf("got error %v, want the failed update of main.go:2", err)
+	}
+
+	if strings.Join(patched, ",") != "1,2" {
+		t.Errorf("got updates of %v, want both tried", patched)
+	}
+}
@@ -0,0 +1,55 @@
+package prcomment
+
+import (
+	"fmt"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
+)
+
+func newGitLab(env env.EnvironmentManager) (Commenter, error) {
+	if env == nil || env.GitlabToken() == "" {
+		return nil, fmt.Errorf("please provide gitlab token in your environment: `GITLAB_TOKEN`")
+	}
+
+	return &gitlab{
+		client: pr.NewGitLab(env),
+	}, nil
+}
+
+type gitlab struct {
+	client *pr.GitLab
+}
+
+// Comment implements Commenter, it starts an inline discussion positioned by the diff refs of the MR
+func (g *gitlab) Comment(mrURL string, comment Comment) error {
+	lineNumber := comment.Line
+	if lineNumber == 0 {
+		lineNumber = 1
+	}
+
+	position, err := g.client.PositionOf(mrURL, comment.FilePath, lineNumber)
+	if err != nil {
+		return err
+	}
+
+	if err := g.client.CreateDiscussion(mrURL, comment.Body(), position); err != nil {
+		// Skip error not to break MR
+		fmt.Printf("failed to post comment. %s: File: %s\n", err, comment.FilePath)
+	}
+
+	return nil
+}
+
+// Reviewed implements Commenter.
+func (g *gitlab) Reviewed(_, _ string) {}
+
+// Submit implements Commenter, the comments are posted right away
+func (g *gitlab) Submit(_, _ string) error {
+	return nil
+}
+
+// Reply implements Replier, the reply is a note of the discussion
+func (g *gitlab) Reply(mrURL string, thread pr.Thread, commentID, body string) error {
+	return g.client.ReplyToThread(mrURL, thread.ID, body+"\n\n"+replyMarker(commentID))
+}
@@ -0,0 +1,92 @@
+package prcomment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// markerRegex matches the hidden marker of the comments posted by qreview
+var markerRegex = regexp.MustCompile(`<!-- qreview definition=("(?:[^"\\]|\\.)*") file=("(?:[^"\\]|\\.)*") fingerprint=([0-9a-f]+) -->`)
+
+// marker identifies a comment across runs, so a re-run does not post the same comment again
+type marker struct {
+	definition  string
+	filePath    string
+	fingerprint string
+}
+
+// Fingerprint identifies the finding of the comment. It does not depend on the line, which moves as the PR changes,
+// nor on the suggestion, so a finding with a new suggestion updates the earlier comment.
+// The same finding on other lines of the file is told apart by its occurrence
+func (c Comment) Fingerprint() string {
+	message := strings.Join(strings.Fields(strings.ToLower(c.Finding.Message)), " ")
+	parts := []string{c.Definition, c.FilePath, c.Finding.Category, message}
+	if c.occurrence > 0 {
+		parts = append(parts, strconv.Itoa(c.occurrence))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// numberOccurrences drops the comments repeating a finding on the same line, and numbers the ones repeating it on other lines
+func numberOccurrences(comments []Comment) []Comment {
+	type occurrence struct {
+		fingerprint string
+		line        int
+	}
+
+	seen := map[occurrence]bool{}
+	counts := map[string]int{}
+	distinct := make([]Comment, 0, len(comments))
+	for _, comment := range comments {
+		fingerprint := comment.Fingerprint()
+		if seen[occurrence{fingerprint, comment.Line}] {
+			continue
+		}
+		seen[occurrence{fingerprint, comment.Line}] = true
+
+		comment.occurrence = counts[fingerprint]
+		counts[fingerprint]++
+		distinct = append(distinct, comment)
+	}
+
+	return distinct
+}
+
+func (c Comment) marker() marker {
+	return marker{
+		definition:  c.Definition,
+		filePath:    c.FilePath,
+		fingerprint: c.Fingerprint(),
+	}
+}
+
+// String renders the marker as an HTML comment, hidden by the markdown of the forge
+func (m marker) String() string {
+	return fmt.Sprintf("<!-- qreview definition=%s file=%s fingerprint=%s -->", strconv.Quote(m.definition), strconv.Quote(m.filePath), m.fingerprint)
+}
+
+// parseMarkers returns the markers found in the body of a comment or a review
+func parseMarkers(body string) []marker {
+	var markers []marker
+	for _, match := range markerRegex.FindAllStringSubmatch(body, -1) {
+		definition, err := strconv.Unquote(match[1])
+		if err != nil {
+			continue
+		}
+
+		filePath, err := strconv.Unquote(match[2])
+		if err != nil {
+			continue
+		}
+
+		markers = append(markers, marker{definition: definition, filePath: filePath, fingerprint: match[3]})
+	}
+
+	return markers
+}
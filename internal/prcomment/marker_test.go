@@ -0,0 +1,36 @@
+package prcomment
+
+import (
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+func TestNumberOccurrencesKeepsTheFindingOnEveryLine(t *testing.T) {
+	finding := reviewparser.Finding{Severity: "major", Message: "error is ignored"}
+	comments := numberOccurrences([]Comment{
+		{Definition: "review", FilePath: "main.go", Line: 10, Finding: finding},
+		{Definition: "review", FilePath: "main.go", Line: 10, Finding: finding}, // Overlapping chunks
+		{Definition: "review", FilePath: "main.go", Line: 42, Finding: finding},
+		{Definition: "review", FilePath: "lib.go", Line: 42, Finding: finding},
+	})
+
+	if len(comments) != 3 {
+		t.Fatalf("got %d comments, want the duplicate on line 10 dropped", len(comments))
+	}
+
+	fingerprints := map[string]bool{}
+	for _, comment := range comments {
+		fingerprints[comment.Fingerprint()] = true
+	}
+
+	if len(fingerprints) != 3 {
+		t.Errorf("got %d distinct fingerprints, want one per comment", len(fingerprints))
+	}
+
+	// The first occurrence keeps the fingerprint of the earlier runs
+	first := Comment{Definition: "review", FilePath: "main.go", Line: 7, Finding: finding}
+	if comments[0].Fingerprint() != first.Fingerprint() {
+		t.Error("got a new fingerprint for the first occurrence")
+	}
+}
@@ -0,0 +1,79 @@
+package prcomment
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/olbrichattila/qreview/internal/pr"
+)
+
+// replyMarkerRegex matches the hidden marker of the replies posted by qreview, with the comment they answer
+var replyMarkerRegex = regexp.MustCompile(`<!-- qreview reply-to=("(?:[^"\\]|\\.)*") -->`)
+
+// Replier is implemented by the commenters of the forges whose review threads can be answered
+type Replier interface {
+	// Reply answers the comment of the thread, the reply is marked so the comment is not answered again
+	Reply(prURL string, thread pr.Thread, commentID, body string) error
+}
+
+// replyMarker renders the marker of a reply to the comment as an HTML comment, hidden by the markdown of the forge
+func replyMarker(commentID string) string {
+	return fmt.Sprintf("<!-- qreview reply-to=%s -->", strconv.Quote(commentID))
+}
+
+// fromQReview tells if the comment was posted by qreview, as a review comment or as a reply
+func fromQReview(body string) bool {
+	return markerRegex.MatchString(body) || replyMarkerRegex.MatchString(body)
+}
+
+// Mention returns the last comment of the thread addressing qreview by the mention, like `@qreview why?`.
+// It is false if there is none, or if qreview has already answered it
+func Mention(thread pr.Thread, mention string) (pr.ThreadComment, bool) {
+	mentionRegex, err := regexp.Compile(`(?i)(^|[^\w@])` + regexp.QuoteMeta(mention) + `\b`)
+	if err != nil || mention == "" {
+		return pr.ThreadComment{}, false
+	}
+
+	answered := map[string]bool{}
+	var last pr.ThreadComment
+	found := false
+	for _, comment := range thread.Comments {
+		if fromQReview(comment.Body) {
+			for _, match := range replyMarkerRegex.FindAllStringSubmatch(comment.Body, -1) {
+				if commentID, err := strconv.Unquote(match[1]); err == nil {
+					answered[commentID] = true
+				}
+			}
+			continue
+		}
+
+		if mentionRegex.MatchString(comment.Body) {
+			last, found = comment, true
+		}
+	}
+
+	if !found || answered[last.ID] {
+		return pr.ThreadComment{}, false
+	}
+
+	return last, true
+}
+
+// Conversation renders the comments of the thread for the model, without the hidden markers
+func Conversation(thread pr.Thread) string {
+	var conversation strings.Builder
+	for _, comment := range thread.Comments {
+		author := comment.Author
+		if fromQReview(comment.Body) {
+			author = "qreview (you)"
+		}
+
+		body := markerRegex.ReplaceAllString(comment.Body, "")
+		body = replyMarkerRegex.ReplaceAllString(body, "")
+		conversation.WriteString(fmt.Sprintf("%s wrote:\n%s\n\n", author, strings.TrimSpace(body)))
+	}
+
+	return strings.TrimSpace(conversation.String())
+}
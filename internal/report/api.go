@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/yuin/goldmark"
 )
@@ -28,6 +29,7 @@ func newAPI(path, reportName string) Reporter {
 type apiReporter struct {
 	path           string
 	reportName     string
+	mu             sync.Mutex
 	processedFiles []string
 }
 
@@ -38,7 +40,9 @@ type apiPayload struct {
 }
 
 // Report implements Reporter.
-func (a *apiReporter) Report(fileName, mdContent string) error {
+func (a *apiReporter) Report(entry Entry) error {
+	fileName, mdContent := entry.FileName, entry.Review.Markdown()
+
 	// Convert markdown to HTML
 	markdown := []byte(mdContent)
 	var buf bytes.Buffer
@@ -78,7 +82,9 @@ func (a *apiReporter) Report(fileName, mdContent string) error {
 	}
 
 	// Add to processed files
+	a.mu.Lock()
 	a.processedFiles = append(a.processedFiles, a.getRelPath(fileName))
+	a.mu.Unlock()
 
 	return nil
 }
@@ -94,10 +100,12 @@ func (a *apiReporter) Summary(fileName string) error {
 	// Create summary content with links to all processed files
 	var summaryContent strings.Builder
 	summaryContent.WriteString("<h1>Code Review Report</h1>\n<ul>\n")
+	a.mu.Lock()
 	for _, file := range a.processedFiles {
 		title := strings.TrimSuffix(file, ".html")
 		summaryContent.WriteString(fmt.Sprintf("  <li><a href=\"%s\">%s</a></li>\n", file, title))
 	}
+	a.mu.Unlock()
 	summaryContent.WriteString("</ul>")
 
 	// Prepare the payload
@@ -8,9 +8,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
 )
 
 //go:embed template/summary-template.html
@@ -46,16 +48,19 @@ type pageData struct {
 type htmlReporter struct {
 	path           string
 	reportName     string
+	mu             sync.Mutex
 	processedFiles []string
 }
 
 // Report implements Reporter.
-func (h *htmlReporter) Report(fileName, mdContent string) error {
+func (h *htmlReporter) Report(entry Entry) error {
+	fileName, mdContent := entry.FileName, entry.Review.Markdown()
+
 	reportFileName := h.getFullPath(fileName)
 	markdown := []byte(mdContent)
 
 	var buf bytes.Buffer
-	if err := goldmark.Convert(markdown, &buf); err != nil {
+	if err := goldmark.New(goldmark.WithExtensions(extension.GFM)).Convert(markdown, &buf); err != nil {
 		return fmt.Errorf("could not convert file %w", err)
 	}
 
@@ -70,7 +75,9 @@ func (h *htmlReporter) Report(fileName, mdContent string) error {
 		return err
 	}
 
+	h.mu.Lock()
 	h.processedFiles = append(h.processedFiles, h.getRelPath(fileName))
+	h.mu.Unlock()
 
 	return nil
 }
@@ -108,12 +115,20 @@ func (h *htmlReporter) Summary(fileName string) error {
 
 	indexHTMLFileName := h.getFullPath(fileName)
 
+	// The folder does not exist yet if every file failed to review
+	if err := os.MkdirAll(filepath.Dir(indexHTMLFileName), os.ModePerm); err != nil {
+		return err
+	}
+
 	file, err := os.Create(indexHTMLFileName)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	items := make([]Item, len(h.processedFiles))
 	for i, processedFile := range h.processedFiles {
 		items[i] = Item{
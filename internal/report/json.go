@@ -0,0 +1,233 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+const ndjsonRecordsFile = "reviews.ndjson"
+
+// record is the machine readable review of a single file
+type record struct {
+	File       string                 `json:"file"`
+	Definition string                 `json:"definition"`
+	Prompt     string                 `json:"prompt"`
+	Backend    string                 `json:"backend"`
+	Model      string                 `json:"model,omitempty"`
+	Response   string                 `json:"response"` // The answer of the model as it is
+	Summary    string                 `json:"summary,omitempty"`
+	Structured bool                   `json:"structured"`
+	Skipped    bool                   `json:"skipped"`
+	Findings   []reviewparser.Finding `json:"findings"`
+	StartedAt  time.Time              `json:"startedAt"`
+	DurationMs int64                  `json:"durationMs"`
+}
+
+// manifest summarizes the run of a definition
+type manifest struct {
+	Definition  string         `json:"definition"`
+	Backend     string         `json:"backend"`
+	Model       string         `json:"model,omitempty"`
+	StartedAt   time.Time      `json:"startedAt"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Files       int            `json:"files"`
+	Skipped     int            `json:"skipped"`
+	Findings    int            `json:"findings"`
+	BySeverity  map[string]int `json:"bySeverity"`
+	DurationMs  int64          `json:"durationMs"` // Sum of the file review times
+	Records     []string       `json:"records"`    // Paths of the record files, relative to the manifest
+}
+
+func newRecord(entry Entry) record {
+	findings := make([]reviewparser.Finding, len(entry.Review.Findings))
+	for i, finding := range entry.Review.Findings {
+		// free text findings do not name the file
+		if finding.File == "" {
+			finding.File = entry.FileName
+		}
+		findings[i] = finding
+	}
+
+	return record{
+		File:       entry.FileName,
+		Definition: entry.Definition,
+		Prompt:     entry.Prompt,
+		Backend:    entry.Backend,
+		Model:      entry.Model,
+		Response:   entry.Review.Raw,
+		Summary:    entry.Review.Summary,
+		Structured: entry.Review.Structured,
+		Skipped:    entry.Skipped,
+		Findings:   findings,
+		StartedAt:  entry.Started,
+		DurationMs: entry.Duration.Milliseconds(),
+	}
+}
+
+// add counts the record into the manifest
+func (m *manifest) add(r record, recordPath string) {
+	if m.Definition == "" {
+		m.Definition, m.Backend, m.Model = r.Definition, r.Backend, r.Model
+	}
+
+	if m.StartedAt.IsZero() || (!r.StartedAt.IsZero() && r.StartedAt.Before(m.StartedAt)) {
+		m.StartedAt = r.StartedAt
+	}
+
+	m.Files++
+	if r.Skipped {
+		m.Skipped++
+	}
+
+	m.Findings += len(r.Findings)
+	for _, finding := range r.Findings {
+		severity := finding.Severity
+		if severity == "" {
+			severity = "unclassified"
+		}
+		m.BySeverity[severity]++
+	}
+
+	m.DurationMs += r.DurationMs
+	if !slices.Contains(m.Records, recordPath) {
+		m.Records = append(m.Records, recordPath)
+	}
+}
+
+func (m *manifest) save(fileName string) error {
+	m.GeneratedAt = time.Now()
+
+	return writeJSON(fileName, m)
+}
+
+// newJSON creates a reporter which writes a JSON record for every file, and the manifest on Summary
+func newJSON(path, reportName string) Reporter {
+	return &jsonReporter{
+		path:       path,
+		reportName: reportName,
+		manifest:   newManifest(),
+	}
+}
+
+type jsonReporter struct {
+	path       string
+	reportName string
+	mu         sync.Mutex
+	manifest   *manifest
+}
+
+// Report implements Reporter.
+func (j *jsonReporter) Report(entry Entry) error {
+	r := newRecord(entry)
+	relPath := entry.FileName + ".json"
+	if err := writeJSON(filepath.Join(j.path, j.reportName, relPath), r); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	j.manifest.add(r, relPath)
+	j.mu.Unlock()
+
+	return nil
+}
+
+// Summary implements Reporter.
+func (j *jsonReporter) Summary(fileName string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.manifest.save(filepath.Join(j.path, j.reportName, fileName+".json"))
+}
+
+// newNDJSON creates a reporter which appends a JSON line for every file to a single file, and writes the manifest on Summary
+func newNDJSON(path, reportName string) Reporter {
+	return &ndjsonReporter{
+		path:       path,
+		reportName: reportName,
+		manifest:   newManifest(),
+	}
+}
+
+type ndjsonReporter struct {
+	path       string
+	reportName string
+	mu         sync.Mutex
+	manifest   *manifest
+	started    bool // The records file of a previous run in the same folder is truncated on the first write
+}
+
+// Report implements Reporter.
+func (n *ndjsonReporter) Report(entry Entry) error {
+	r := newRecord(entry)
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("could not marshal the review of %s: %w", entry.FileName, err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	fileName := filepath.Join(n.path, n.reportName, ndjsonRecordsFile)
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return err
+	}
+
+	flags := os.O_CREATE | os.O_APPEND | os.O_WRONLY
+	if !n.started {
+		flags |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(fileName, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open %s, %w", fileName, err)
+	}
+	defer file.Close()
+	n.started = true
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write %s, %w", fileName, err)
+	}
+
+	n.manifest.add(r, ndjsonRecordsFile)
+
+	return nil
+}
+
+// Summary implements Reporter.
+func (n *ndjsonReporter) Summary(fileName string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.manifest.save(filepath.Join(n.path, n.reportName, fileName+".json"))
+}
+
+func newManifest() *manifest {
+	return &manifest{
+		BySeverity: map[string]int{},
+		Records:    []string{},
+	}
+}
+
+func writeJSON(fileName string, value any) error {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal %s: %w", fileName, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(fileName, content, 0644); err != nil {
+		return fmt.Errorf("could not save JSON file %s, %w", fileName, err)
+	}
+
+	return nil
+}
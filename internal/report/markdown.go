@@ -15,8 +15,8 @@ type mdReporter struct {
 }
 
 // Report implements Reporter.
-func (m *mdReporter) Report(_, mdContent string) error {
-	m.displayMd(mdContent)
+func (m *mdReporter) Report(entry Entry) error {
+	m.displayMd(entry.Review.Markdown())
 	return nil
 }
 
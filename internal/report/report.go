@@ -1,19 +1,42 @@
 // Report creates a report from the answer to the provided formats
 package report
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
 
 type Kind string
 
 const (
-	KindHTML     Kind = "html"
-	KindMarkdown Kind = "markdown"
-	KindSave     Kind = "save"
-	KindAPI      Kind = "api"
+	KindHTML       Kind = "html"
+	KindMarkdown   Kind = "markdown"
+	KindSave       Kind = "save"
+	KindAPI        Kind = "api"
+	KindSARIF      Kind = "sarif"
+	KindJSON       Kind = "json"
+	KindNDJSON     Kind = "ndjson"
+	KindJUnit      Kind = "junit"
+	KindCheckstyle Kind = "checkstyle"
 )
 
+// Entry is the review of a single file
+type Entry struct {
+	FileName   string
+	Review     reviewparser.Response
+	Definition string // Name of the definition which made the review
+	Prompt     string
+	Backend    string
+	Model      string
+	Skipped    bool // The file was too large for the model
+	Started    time.Time
+	Duration   time.Duration
+}
+
 type Reporter interface {
-	Report(fileName, mdContent string) error
+	Report(entry Entry) error
 	Summary(fileName string) error
 }
 
@@ -27,6 +50,16 @@ func New(rType Kind, path, reportName string) (Reporter, error) {
 		return newSaveResponse(path, reportName), nil
 	case KindAPI:
 		return newAPI(path, reportName), nil
+	case KindSARIF:
+		return newSARIF(path, reportName), nil
+	case KindJSON:
+		return newJSON(path, reportName), nil
+	case KindNDJSON:
+		return newNDJSON(path, reportName), nil
+	case KindJUnit:
+		return newJUnit(path, reportName), nil
+	case KindCheckstyle:
+		return newCheckstyle(path, reportName), nil
 	default:
 		return nil, fmt.Errorf("invalid report type %s", rType)
 	}
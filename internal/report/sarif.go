@@ -0,0 +1,211 @@
+package report
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+const (
+	sarifVersion  = "2.1.0"
+	sarifSchema   = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifToolName = "qreview"
+	sarifToolURI  = "https://github.com/olbrichattila/qreview"
+)
+
+var ruleIDRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// newSARIF creates a reporter which collects the findings of every file and writes them as a SARIF log on Summary
+func newSARIF(path, reportName string) Reporter {
+	return &sarifReporter{
+		path:       path,
+		reportName: reportName,
+		rules:      map[string]bool{},
+	}
+}
+
+type sarifReporter struct {
+	path       string
+	reportName string
+	mu         sync.Mutex
+	ruleIDs    []string
+	rules      map[string]bool
+	results    []sarifResult
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+// Report implements Reporter. The line numbers of the findings are already mapped back to the lines of the file
+func (s *sarifReporter) Report(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, finding := range entry.Review.Findings {
+		if strings.TrimSpace(finding.Message) == "" {
+			continue
+		}
+
+		fileName := finding.File
+		if fileName == "" {
+			fileName = entry.FileName
+		}
+
+		ruleID := s.ruleID(finding.Category)
+		if !s.rules[ruleID] {
+			s.rules[ruleID] = true
+			s.ruleIDs = append(s.ruleIDs, ruleID)
+		}
+
+		s.results = append(s.results, sarifResult{
+			RuleID:  ruleID,
+			Level:   sarifLevel(finding.Severity),
+			Message: sarifMessageOf(finding),
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{
+						URI:       filepath.ToSlash(fileName),
+						URIBaseID: "%SRCROOT%",
+					},
+					Region: sarifRegion{
+						StartLine: max(finding.StartLine, 1),
+						EndLine:   max(finding.EndLine, finding.StartLine, 1),
+					},
+				},
+			}},
+		})
+	}
+
+	return nil
+}
+
+// Summary implements Reporter.
+func (s *sarifReporter) Summary(fileName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := make([]sarifRule, len(s.ruleIDs))
+	for i, ruleID := range s.ruleIDs {
+		rules[i] = sarifRule{
+			ID:               ruleID,
+			ShortDescription: sarifMessage{Text: sarifToolName + " " + ruleID + " findings"},
+		}
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:           sarifToolName,
+					InformationURI: sarifToolURI,
+					Rules:          rules,
+				},
+			},
+			Results: s.results,
+		}},
+	}
+
+	if log.Runs[0].Results == nil {
+		// an empty log still has to have the results array
+		log.Runs[0].Results = []sarifResult{}
+	}
+
+	return writeJSON(s.getFullPath(fileName), log)
+}
+
+// ruleID returns the rule of the category, like review/security
+func (s *sarifReporter) ruleID(category string) string {
+	category = strings.Trim(ruleIDRegex.ReplaceAllString(strings.ToLower(category), "-"), "-")
+	if category == "" {
+		category = "general"
+	}
+
+	return s.reportName + "/" + category
+}
+
+func (s *sarifReporter) getFullPath(fileName string) string {
+	return filepath.Join(s.path, s.reportName, fileName+".sarif")
+}
+
+// sarifLevel maps the severity to the SARIF result level, unclassified findings are notes
+func sarifLevel(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "major":
+		return "error"
+	case "minor":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+func sarifMessageOf(finding reviewparser.Finding) sarifMessage {
+	text := finding.Message
+	if finding.Suggestion != "" {
+		text += "\n\nSuggestion: " + finding.Suggestion
+	}
+
+	return sarifMessage{
+		Text:     text,
+		Markdown: finding.Markdown(),
+	}
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+func TestSARIFReportHasTheRulesLevelsAndRegions(t *testing.T) {
+	dir := t.TempDir()
+	reporter := newSARIF(dir, "review")
+
+	entries := []Entry{
+		{FileName: filepath.Join("internal", "a.go"), Review: reviewparser.Response{Findings: []reviewparser.Finding{
+			{StartLine: 3, EndLine: 5, Severity: "critical", Category: "Security", Message: "sql injection"},
+			{StartLine: 9, Severity: "minor", Category: "Code Style", Message: "naming"},
+			{Severity: "", Message: "no line"},
+			{StartLine: 12, Message: " "},
+		}}},
+		{FileName: "b.go", Review: reviewparser.Response{Findings: []reviewparser.Finding{
+			{File: "other.go", StartLine: 7, EndLine: 4, Severity: "major", Category: "security", Message: "unchecked input"},
+		}}},
+	}
+
+	for _, entry := range entries {
+		if err := reporter.Report(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := reporter.Summary("index"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "review", "index.sarif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(content, &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if log.Version != sarifVersion || len(log.Runs) != 1 {
+		t.Fatalf("got version %s with %d runs", log.Version, len(log.Runs))
+	}
+
+	run := log.Runs[0]
+	var ruleIDs []string
+	for _, rule := range run.Tool.Driver.Rules {
+		ruleIDs = append(ruleIDs, rule.ID)
+	}
+
+	// The categories are normalized, each rule is listed once
+	if len(ruleIDs) != 3 || ruleIDs[0] != "review/security" || ruleIDs[1] != "review/code-style" || ruleIDs[2] != "review/general" {
+		t.Errorf("got rules %v", ruleIDs)
+	}
+
+	// The finding without a message is left out
+	wantResults := []struct {
+		ruleID    string
+		level     string
+		uri       string
+		startLine int
+		endLine   int
+	}{
+		{"review/security", "error", "internal/a.go", 3, 5},
+		{"review/code-style", "warning", "internal/a.go", 9, 9},
+		{"review/general", "note", "internal/a.go", 1, 1},
+		{"review/security", "error", "other.go", 7, 7},
+	}
+
+	if len(run.Results) != len(wantResults) {
+		t.Fatalf("got %d results, want %d", len(run.Results), len(wantResults))
+	}
+
+	for i, result := range run.Results {
+		location := result.Locations[0].PhysicalLocation
+		want := wantResults[i]
+		if result.RuleID != want.ruleID || result.Level != want.level || location.ArtifactLocation.URI != want.uri ||
+			location.Region.StartLine != want.startLine || location.Region.EndLine != want.endLine {
+			t.Errorf("got result %d %+v, want %+v", i, result, want)
+		}
+
+		if location.ArtifactLocation.URIBaseID != "%SRCROOT%" {
+			t.Errorf("got uri base %q", location.ArtifactLocation.URIBaseID)
+		}
+	}
+}
+
+func TestSARIFReportWithoutFindingsHasEmptyResults(t *testing.T) {
+	dir := t.TempDir()
+	reporter := newSARIF(dir, "review")
+	if err := reporter.Summary("index"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "review", "index.sarif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Runs []struct {
+			Results []json.RawMessage `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(content, &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(log.Runs) != 1 || log.Runs[0].Results == nil {
+		t.Errorf("got %s, want an empty results array", content)
+	}
+}
@@ -24,7 +24,9 @@ func newSaveResponse(path, reportName string) Reporter {
 }
 
 // Report implements Reporter.
-func (h *saveReporter) Report(fileName, mdContent string) error {
+func (h *saveReporter) Report(entry Entry) error {
+	fileName, mdContent := entry.FileName, entry.Review.Markdown()
+
 	reportFileName := h.getFullPath(fileName)
 
 	err := os.MkdirAll(filepath.Dir(reportFileName), os.ModePerm)
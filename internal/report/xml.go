@@ -0,0 +1,298 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+// junitTestSuites is the root of a JUnit XML report
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Name    string           `xml:"name,attr"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// newJUnit creates a reporter which writes a JUnit XML report on Summary, every file is a testcase.
+// A file with critical or major findings is an error, with minor ones a failure, the message combines these findings.
+// The rest is only logged to the output of the testcase
+func newJUnit(path, reportName string) Reporter {
+	return &junitReporter{
+		path:       path,
+		reportName: reportName,
+	}
+}
+
+type junitReporter struct {
+	path       string
+	reportName string
+	mu         sync.Mutex
+	suite      junitTestSuite
+	seconds    float64
+}
+
+// Report implements Reporter.
+func (j *junitReporter) Report(entry Entry) error {
+	testCase := junitTestCase{
+		ClassName: j.reportName,
+		Name:      entry.FileName,
+		Time:      fmt.Sprintf("%.3f", entry.Duration.Seconds()),
+	}
+
+	if entry.Skipped {
+		testCase.Skipped = &junitMessage{Message: singleLine(entry.Review.Raw)}
+	}
+
+	var reported []reviewparser.Finding
+	var output []string
+	hasError := false
+	for _, finding := range entry.Review.Findings {
+		if strings.TrimSpace(finding.Message) == "" {
+			continue
+		}
+
+		switch checkstyleSeverity(finding.Severity) {
+		case "error":
+			hasError = true
+			reported = append(reported, finding)
+		case "warning":
+			reported = append(reported, finding)
+		default:
+			output = append(output, findingText(entry.FileName, finding))
+		}
+	}
+	testCase.SystemOut = strings.Join(output, "\n\n")
+
+	if len(reported) > 0 {
+		message := junitFindings(entry.FileName, reported)
+		if hasError {
+			testCase.Error = message
+		} else {
+			testCase.Failure = message
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.suite.Tests++
+	if testCase.Skipped != nil {
+		j.suite.Skipped++
+	}
+	if testCase.Error != nil {
+		j.suite.Errors++
+	}
+	if testCase.Failure != nil {
+		j.suite.Failures++
+	}
+	j.suite.Cases = append(j.suite.Cases, testCase)
+	j.seconds += entry.Duration.Seconds()
+
+	return nil
+}
+
+// junitFindings combines the findings of a file into the message of its error or failure, a testcase has only one
+func junitFindings(fileName string, findings []reviewparser.Finding) *junitMessage {
+	message := fmt.Sprintf("Line %d: %s", findings[0].StartLine, firstLine(findings[0].Message))
+	if len(findings) > 1 {
+		message = fmt.Sprintf("%d findings, first on line %d: %s", len(findings), findings[0].StartLine, firstLine(findings[0].Message))
+	}
+
+	severities := make([]string, 0, len(findings))
+	texts := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		severity := severityOrDefault(finding.Severity)
+		if !slices.Contains(severities, severity) {
+			severities = append(severities, severity)
+		}
+		texts = append(texts, "["+severity+"] "+findingText(fileName, finding))
+	}
+
+	return &junitMessage{
+		Message: message,
+		Type:    strings.Join(severities, ","),
+		Text:    strings.Join(texts, "\n\n"),
+	}
+}
+
+// Summary implements Reporter.
+func (j *junitReporter) Summary(fileName string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	suite := j.suite
+	suite.Name = j.reportName
+	suite.Time = fmt.Sprintf("%.3f", j.seconds)
+
+	return writeXML(filepath.Join(j.path, j.reportName, fileName+".junit.xml"), junitTestSuites{
+		Name:   sarifToolName,
+		Suites: []junitTestSuite{suite},
+	})
+}
+
+// checkstyleReport is the root of a Checkstyle XML report
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// newCheckstyle creates a reporter which writes a Checkstyle XML report on Summary, every finding is an error entry of its file
+func newCheckstyle(path, reportName string) Reporter {
+	return &checkstyleReporter{
+		path:       path,
+		reportName: reportName,
+	}
+}
+
+type checkstyleReporter struct {
+	path       string
+	reportName string
+	mu         sync.Mutex
+	files      []checkstyleFile
+}
+
+// Report implements Reporter.
+func (c *checkstyleReporter) Report(entry Entry) error {
+	file := checkstyleFile{Name: entry.FileName}
+	for _, finding := range entry.Review.Findings {
+		if strings.TrimSpace(finding.Message) == "" {
+			continue
+		}
+
+		category := finding.Category
+		if category == "" {
+			category = "general"
+		}
+
+		file.Errors = append(file.Errors, checkstyleError{
+			Line:     max(finding.StartLine, 1),
+			Severity: checkstyleSeverity(finding.Severity),
+			Message:  findingText("", finding),
+			Source:   sarifToolName + "." + c.reportName + "." + category,
+		})
+	}
+
+	c.mu.Lock()
+	c.files = append(c.files, file)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Summary implements Reporter.
+func (c *checkstyleReporter) Summary(fileName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return writeXML(filepath.Join(c.path, c.reportName, fileName+".checkstyle.xml"), checkstyleReport{
+		Version: "4.3",
+		Files:   c.files,
+	})
+}
+
+// checkstyleSeverity maps the severity to the Checkstyle levels, unclassified findings are info
+func checkstyleSeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "major":
+		return "error"
+	case "minor":
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+func severityOrDefault(severity string) string {
+	if severity == "" {
+		return "unclassified"
+	}
+
+	return severity
+}
+
+// findingText is the plain text of the finding, prefixed with the location if the file name is given
+func findingText(fileName string, finding reviewparser.Finding) string {
+	var text strings.Builder
+	if fileName != "" {
+		text.WriteString(fmt.Sprintf("%s:%d\n", fileName, finding.StartLine))
+	}
+
+	text.WriteString(finding.Message)
+	if finding.Suggestion != "" {
+		text.WriteString("\n\nSuggestion: " + finding.Suggestion)
+	}
+
+	return text.String()
+}
+
+func firstLine(str string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(str), "\n")
+	return line
+}
+
+func singleLine(str string) string {
+	return strings.Join(strings.Fields(str), " ")
+}
+
+func writeXML(fileName string, value any) error {
+	content, err := xml.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal %s: %w", fileName, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return err
+	}
+
+	content = append([]byte(xml.Header), content...)
+	if err := os.WriteFile(fileName, content, 0644); err != nil {
+		return fmt.Errorf("could not save XML file %s, %w", fileName, err)
+	}
+
+	return nil
+}
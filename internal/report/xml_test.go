@@ -0,0 +1,92 @@
+package report
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+func TestJUnitReportHasOneOutcomePerTestcase(t *testing.T) {
+	dir := t.TempDir()
+	reporter := newJUnit(dir, "review")
+
+	entries := []Entry{
+		{FileName: "a.go", Review: reviewparser.Response{Findings: []reviewparser.Finding{
+			{StartLine: 3, Severity: "major", Message: "nil map write"},
+			{StartLine: 9, Severity: "critical", Message: "sql injection"},
+			{StartLine: 12, Severity: "minor", Message: "naming"},
+			{StartLine: 15, Severity: "info", Message: "consider a table test"},
+		}}},
+		{FileName: "b.go", Review: reviewparser.Response{Findings: []reviewparser.Finding{
+			{StartLine: 4, Severity: "minor", Message: "naming"},
+			{StartLine: 8, Severity: "minor", Message: "typo"},
+		}}},
+		{FileName: "c.go"},
+	}
+
+	for _, entry := range entries {
+		if err := reporter.Report(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := reporter.Summary("index"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "review", "index.junit.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoding into slices catches repeated error and failure elements
+	var report struct {
+		Suite struct {
+			Tests    int `xml:"tests,attr"`
+			Errors   int `xml:"errors,attr"`
+			Failures int `xml:"failures,attr"`
+			Cases    []struct {
+				Name     string         `xml:"name,attr"`
+				Errors   []junitMessage `xml:"error"`
+				Failures []junitMessage `xml:"failure"`
+				Out      string         `xml:"system-out"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(content, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	suite := report.Suite
+	if suite.Tests != 3 || suite.Errors != 1 || suite.Failures != 1 {
+		t.Errorf("got %d tests, %d errors, %d failures, want 3, 1, 1", suite.Tests, suite.Errors, suite.Failures)
+	}
+
+	a := suite.Cases[0]
+	if len(a.Errors) != 1 || len(a.Failures) != 0 {
+		t.Fatalf("got %d errors and %d failures on a.go, want a single error", len(a.Errors), len(a.Failures))
+	}
+
+	for _, message := range []string{"nil map write", "sql injection", "naming"} {
+		if !strings.Contains(a.Errors[0].Text, message) {
+			t.Errorf("the error of a.go misses %q", message)
+		}
+	}
+
+	if !strings.Contains(a.Out, "consider a table test") {
+		t.Errorf("got output %q, want the info finding", a.Out)
+	}
+
+	b := suite.Cases[1]
+	if len(b.Errors) != 0 || len(b.Failures) != 1 || !strings.HasPrefix(b.Failures[0].Message, "2 findings") {
+		t.Errorf("got errors %v and failures %v on b.go, want a single failure", b.Errors, b.Failures)
+	}
+
+	if c := suite.Cases[2]; len(c.Errors) != 0 || len(c.Failures) != 0 {
+		t.Errorf("got errors %v and failures %v on c.go, want none", c.Errors, c.Failures)
+	}
+}
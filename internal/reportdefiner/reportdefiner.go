@@ -4,6 +4,9 @@ package reportdefiner
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/olbrichattila/qreview/internal/env"
 	"github.com/olbrichattila/qreview/internal/report"
@@ -23,12 +26,69 @@ type ReviewerDefinitions []ReviewerDefinition
 
 // ReviewerDefinition contains AI prompt, the retriever kind, which is file or diff and list of reporters, html, markdown...
 type ReviewerDefinition struct {
+	Name          string               `yaml:"name"`
 	Prompt        string               `yaml:"prompt"`
 	RetrieverKind retriever.Kind       `yaml:"retrieverKind"`
 	CommentOnPr   bool                 `yaml:"commentOnPr"`
+	OutputFormat  review.OutputFormat  `yaml:"outputFormat"` // text (default) or json
+	Model         ModelDefinition      `yaml:"model"`
 	Reporters     []ReporterDefinition `yaml:"reporters"`
 }
 
+// ModelDefinition overrides the model settings of the environment for the definition, timeout is in seconds
+type ModelDefinition struct {
+	ID             string   `yaml:"id"`
+	MaxTokens      int      `yaml:"maxTokens"`
+	Temperature    *float64 `yaml:"temperature"`
+	SystemPrompt   string   `yaml:"systemPrompt"`
+	Timeout        int      `yaml:"timeout"`
+	MaxInputTokens int      `yaml:"maxInputTokens"` // Larger files are reviewed in chunks
+}
+
+func (m ModelDefinition) options() review.ModelOptions {
+	return review.ModelOptions{
+		ModelID:        m.ID,
+		MaxTokens:      m.MaxTokens,
+		Temperature:    m.Temperature,
+		SystemPrompt:   m.SystemPrompt,
+		Timeout:        time.Duration(m.Timeout) * time.Second,
+		MaxInputTokens: m.MaxInputTokens,
+	}
+}
+
+// name returns the definition name, defaults to the name of the first reporter, or the position in the list
+func (r ReviewerDefinition) name(index int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+
+	for _, reporter := range r.Reporters {
+		if reporter.Name != "" {
+			return reporter.Name
+		}
+	}
+
+	return fmt.Sprintf("definition-%d", index+1)
+}
+
+// outputFormat returns the output format the model is asked to answer in, free text if not set
+func (r ReviewerDefinition) outputFormat(name string) (review.OutputFormat, error) {
+	if r.OutputFormat == "" {
+		return review.OutputText, nil
+	}
+
+	if !slices.Contains(review.OutputFormats, r.OutputFormat) {
+		allowed := make([]string, 0, len(review.OutputFormats))
+		for _, format := range review.OutputFormats {
+			allowed = append(allowed, string(format))
+		}
+
+		return "", fmt.Errorf("definition %s: unknown output format %q, allowed values are %s", name, r.OutputFormat, strings.Join(allowed, ", "))
+	}
+
+	return r.OutputFormat, nil
+}
+
 // ReporterDefinition defines a reporter, for it's kind with folder and name, Folder may not required if reporter does not save
 type ReporterDefinition struct {
 	Kind   report.Kind `yaml:"kind"` // Replace with report.Kind if available
@@ -53,6 +113,9 @@ func Load(envManager env.EnvironmentManager, yamlFileName, reportFolder string)
 	}
 
 	err = yaml.Unmarshal(data, &defs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", yamlFileName, err)
+	}
 
 	for i := 0; i < len(defs); i++ {
 		for x := 0; x < len(defs[i].Reporters); x++ {
@@ -66,6 +129,7 @@ func Load(envManager env.EnvironmentManager, yamlFileName, reportFolder string)
 func GetDefaultReviewers(envManager env.EnvironmentManager, reportFolder string) ([]review.Reviewer, error) {
 	def := ReviewerDefinitions{
 		{
+			Name:          typeReview,
 			Prompt:        review.PromptReview,
 			RetrieverKind: retriever.KindSmartMixed, // Use smart mixed retriever for code review
 			CommentOnPr:   true,
@@ -76,6 +140,7 @@ func GetDefaultReviewers(envManager env.EnvironmentManager, reportFolder string)
 			},
 		},
 		{
+			Name:          typeDocumentation,
 			Prompt:        review.PromptExplainCode,
 			RetrieverKind: retriever.KindFile,
 			CommentOnPr:   false,
@@ -86,6 +151,7 @@ func GetDefaultReviewers(envManager env.EnvironmentManager, reportFolder string)
 			},
 		},
 		{
+			Name:          typeUpdateDocumentations,
 			Prompt:        review.PromptExplainChanges,
 			RetrieverKind: retriever.KindDiff,
 			CommentOnPr:   false,
@@ -108,7 +174,17 @@ func GetReviewers(envManager env.EnvironmentManager, reviewerDefinitions Reviewe
 	}
 
 	reviewers := []review.Reviewer{}
-	for _, reviewerDefinition := range reviewerDefinitions {
+	for i, reviewerDefinition := range reviewerDefinitions {
+		name := reviewerDefinition.name(i)
+		outputFormat, err := reviewerDefinition.outputFormat(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := review.CheckModelOptions(envManager, reviewerDefinition.Model.options()); err != nil {
+			return nil, fmt.Errorf("definition %s: %w", name, err)
+		}
+
 		currentRetriever, err := getRetrievers(envManager, reviewerDefinition.RetrieverKind)
 		if err != nil {
 			return nil, err
@@ -121,7 +197,16 @@ func GetReviewers(envManager env.EnvironmentManager, reviewerDefinitions Reviewe
 
 		reviewers = append(
 			reviewers,
-			review.New(envManager, currentRetriever, currentReporters, reviewerDefinition.Prompt, reviewerDefinition.CommentOnPr),
+			review.New(
+				envManager,
+				name,
+				currentRetriever,
+				currentReporters,
+				reviewerDefinition.Prompt,
+				reviewerDefinition.CommentOnPr,
+				reviewerDefinition.Model.options(),
+				outputFormat,
+			),
 		)
 	}
 
@@ -147,7 +232,7 @@ func initRetrievers(envManager env.EnvironmentManager) error {
 	if err != nil {
 		return err
 	}
-	
+
 	// Initialize the smart mixed retriever
 	smartMixedRetriever, err = retriever.NewSmartMixed(envManager, fileRetriever, diffRetriever)
 	if err != nil {
@@ -0,0 +1,20 @@
+package reportdefiner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/review"
+)
+
+func TestOutputFormatRejectsUnknownValues(t *testing.T) {
+	format, err := ReviewerDefinition{}.outputFormat("review")
+	if err != nil || format != review.OutputText {
+		t.Errorf("got %q, %v, want text by default", format, err)
+	}
+
+	_, err = ReviewerDefinition{OutputFormat: "JSON"}.outputFormat("review")
+	if err == nil || !strings.Contains(err.Error(), "allowed values are text, json") {
+		t.Errorf("got error %v, want the allowed values", err)
+	}
+}
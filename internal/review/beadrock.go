@@ -3,6 +3,9 @@ package review
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"encoding/json"
@@ -10,31 +13,165 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
-	"github.com/olbrichattila/qreview/internal/helpers"
-	"github.com/olbrichattila/qreview/internal/report"
-	"github.com/olbrichattila/qreview/internal/retriever"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/olbrichattila/qreview/internal/cache"
+	"github.com/olbrichattila/qreview/internal/env"
 )
 
-// newBedrock creates a new AWS bedrock reviewer using the AWS SDK
-func newBedrock(
-	retr retriever.Retriever,
-	prompt string,
-	reporters []report.Reporter,
-	commentOnPR bool,
-) Reviewer {
+const (
+	bedrockAPIConverse = "converse"
+	bedrockAPIInvoke   = "invoke"
+
+	// bedrockMinInputTokens is the smallest input budget, the chunks are never smaller, unless the context window is
+	bedrockMinInputTokens = 2000
+
+	// bedrockDefaultContextWindow is assumed for unknown models, small enough for every model family
+	bedrockDefaultContextWindow = 8192
+)
+
+// bedrockContextWindows are the context windows in tokens by model ID prefix, the longest matching prefix wins
+var bedrockContextWindows = map[string]int{
+	"anthropic.":                100000,
+	"meta.llama3":               8192,
+	"meta.llama3-1":             128000,
+	"meta.llama3-2":             128000,
+	"meta.llama3-3":             128000,
+	"mistral.":                  32000,
+	"amazon.titan-text-express": 8192,
+	"amazon.titan-text-lite":    4096,
+	"amazon.nova":               300000,
+	"cohere.command-r":          128000,
+}
+
+// The Bedrock client is created once per run and shared by all the definitions
+var (
+	bedrockClientCache *bedrockruntime.Client
+	bedrockClientErr   error
+	bedrockClientOnce  sync.Once
+)
+
+// newBedrock creates a new AWS bedrock model using the AWS SDK
+func newBedrock(env env.EnvironmentManager, modelOptions ModelOptions) Model {
+	settings := bedrockSettings{
+		modelID:      env.BedrockModelID(),
+		api:          env.BedrockAPI(),
+		maxTokens:    env.BedrockMaxTokens(),
+		systemPrompt: env.BedrockSystemPrompt(),
+		timeout:      time.Duration(env.BedrockTimeout()) * time.Second,
+	}
+
+	if temperature, ok := env.BedrockTemperature(); ok {
+		settings.temperature = &temperature
+	}
+
+	settings = settings.override(modelOptions)
+	contextWindow := env.BedrockContextWindow()
+	if contextWindow <= 0 {
+		contextWindow = bedrockContextWindow(settings.modelID)
+	}
+
 	return &bedrock{
-		reporters:   reporters,
-		retr:        retr,
-		prompt:      prompt,
-		commentOnPR: commentOnPR,
+		env:           env,
+		settings:      settings,
+		contextWindow: contextWindow,
 	}
 }
 
 type bedrock struct {
-	reporters   []report.Reporter
-	retr        retriever.Retriever
-	prompt      string
-	commentOnPR bool
+	env           env.EnvironmentManager
+	settings      bedrockSettings
+	contextWindow int // In tokens, the input and the answer together
+}
+
+// bedrockContextWindow returns the context window of the model by its ID, or the default for unknown models
+func bedrockContextWindow(modelID string) int {
+	window, matched := bedrockDefaultContextWindow, ""
+	for prefix, size := range bedrockContextWindows {
+		if strings.HasPrefix(modelID, prefix) && len(prefix) > len(matched) {
+			window, matched = size, prefix
+		}
+	}
+
+	return window
+}
+
+// String returns the backend and model name
+func (a *bedrock) String() string {
+	return fmt.Sprintf("%s/%s", clientBedrock, a.settings.modelID)
+}
+
+// cacheIdentity implements cacheIdentified, the settings shaping the answer are hashed, the timeout is not
+func (a *bedrock) cacheIdentity() string {
+	temperature := ""
+	if a.settings.temperature != nil {
+		temperature = strconv.FormatFloat(*a.settings.temperature, 'g', -1, 64)
+	}
+
+	return a.String() + "/" + cache.Key(
+		a.settings.api,
+		strconv.Itoa(a.settings.maxTokens),
+		temperature,
+		a.settings.systemPrompt,
+	)
+}
+
+// tokenBudget implements budgeted, the answer has to fit into the context window as well.
+// A max tokens near or above the context window leaves the minimum, not an unlimited budget
+func (a *bedrock) tokenBudget() TokenBudget {
+	minInputTokens := min(bedrockMinInputTokens, a.contextWindow/2)
+	charsPerToken := 4.0
+	if strings.HasPrefix(a.settings.modelID, "anthropic.") {
+		charsPerToken = 3.5
+	}
+
+	return TokenBudget{MaxInputTokens: max(a.contextWindow-a.settings.maxTokens, minInputTokens), CharsPerToken: charsPerToken}
+}
+
+// bedrockSettings are the resolved model settings, environment first, then the definition overrides
+type bedrockSettings struct {
+	modelID      string
+	api          string
+	maxTokens    int
+	temperature  *float64
+	systemPrompt string
+	timeout      time.Duration
+}
+
+func (s bedrockSettings) override(modelOptions ModelOptions) bedrockSettings {
+	if modelOptions.ModelID != "" {
+		s.modelID = modelOptions.ModelID
+	}
+
+	if modelOptions.MaxTokens > 0 {
+		s.maxTokens = modelOptions.MaxTokens
+	}
+
+	if modelOptions.Temperature != nil {
+		s.temperature = modelOptions.Temperature
+	}
+
+	if modelOptions.SystemPrompt != "" {
+		s.systemPrompt = modelOptions.SystemPrompt
+	}
+
+	if modelOptions.Timeout > 0 {
+		s.timeout = modelOptions.Timeout
+	}
+
+	return s
+}
+
+// useConverse tells if the Converse API should be used. The legacy InvokeModel body is Anthropic only,
+// so when the API is not set explicitly every other model family goes through Converse
+func (s bedrockSettings) useConverse() bool {
+	switch s.api {
+	case bedrockAPIConverse:
+		return true
+	case bedrockAPIInvoke:
+		return false
+	default:
+		return !strings.HasPrefix(s.modelID, "anthropic.")
+	}
 }
 
 // Claude message structure
@@ -47,6 +184,8 @@ type claudeMessage struct {
 type claudeRequest struct {
 	AnthropicVersion string          `json:"anthropic_version"`
 	MaxTokens        int             `json:"max_tokens"`
+	System           string          `json:"system,omitempty"`
+	Temperature      *float64        `json:"temperature,omitempty"`
 	Messages         []claudeMessage `json:"messages"`
 }
 
@@ -58,31 +197,105 @@ type claudeResponse struct {
 	} `json:"content"`
 }
 
-// AnalyzeCode returns the result of the analyzed code using Amazon Q via AWS SDK
-func (a *bedrock) AnalyzeCode(fileName string) error {
-	content, err := a.retr.Get(fileName)
+// Complete implements Model.
+func (a *bedrock) Complete(ctx context.Context, request Request) (Response, error) {
+	bedrockClient, err := a.client()
 	if err != nil {
-		return fmt.Errorf("Analyze code %w", err)
+		return Response{}, err
 	}
 
-	remappedContent, lineMap := helpers.SourceCodeLineRemap(content.FileContent)
+	// Set a timeout for the API call
+	ctx, cancel := withTimeout(ctx, a.settings.timeout)
+	defer cancel()
+
+	fmt.Printf("executing Bedrock command, model %s\n", a.settings.modelID)
+	var aiResponse string
+	if a.settings.useConverse() {
+		aiResponse, err = a.converse(ctx, bedrockClient, request.Message())
+	} else {
+		aiResponse, err = a.invoke(ctx, bedrockClient, request.Message())
+	}
 
-	// Load AWS configuration from environment variables or shared credentials file
-	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
-		return fmt.Errorf("failed to load AWS configuration: %w", err)
+		return Response{}, err
 	}
+	fmt.Println("executed Bedrock command")
+
+	return Response{Text: aiResponse}, nil
+}
 
-	// Create Amazon Bedrock Runtime client (which is used for Amazon Q)
-	bedrockClient := bedrockruntime.NewFromConfig(cfg)
+// client returns the shared Bedrock runtime client, loading the AWS configuration on first use
+func (a *bedrock) client() (*bedrockruntime.Client, error) {
+	bedrockClientOnce.Do(func() {
+		// Load AWS configuration from environment variables or shared credentials file
+		cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(a.env.AwsRegion()))
+		if err != nil {
+			bedrockClientErr = fmt.Errorf("failed to load AWS configuration: %w", err)
+			return
+		}
+
+		bedrockClientCache = bedrockruntime.NewFromConfig(cfg)
+	})
+
+	return bedrockClientCache, bedrockClientErr
+}
 
-	// Prepare the message with the prompt and file content
-	message := a.prompt + remappedContent
+// converse calls the model through the model agnostic Converse API
+func (a *bedrock) converse(ctx context.Context, bedrockClient *bedrockruntime.Client, message string) (string, error) {
+	inferenceConfig := &types.InferenceConfiguration{
+		MaxTokens: aws.Int32(int32(a.settings.maxTokens)),
+	}
+
+	if a.settings.temperature != nil {
+		inferenceConfig.Temperature = aws.Float32(float32(*a.settings.temperature))
+	}
+
+	input := &bedrockruntime.ConverseInput{
+		ModelId: aws.String(a.settings.modelID),
+		Messages: []types.Message{
+			{
+				Role: types.ConversationRoleUser,
+				Content: []types.ContentBlock{
+					&types.ContentBlockMemberText{Value: message},
+				},
+			},
+		},
+		InferenceConfig: inferenceConfig,
+	}
+
+	if a.settings.systemPrompt != "" {
+		input.System = []types.SystemContentBlock{
+			&types.SystemContentBlockMemberText{Value: a.settings.systemPrompt},
+		}
+	}
 
-	// Create the Claude request (Amazon Q uses Claude under the hood)
+	output, err := bedrockClient.Converse(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to get response from Amazon Bedrock: %w", err)
+	}
+
+	outputMessage, ok := output.Output.(*types.ConverseOutputMemberMessage)
+	if !ok {
+		return "", fmt.Errorf("unexpected Amazon Bedrock converse output %T", output.Output)
+	}
+
+	aiResponse := ""
+	for _, block := range outputMessage.Value.Content {
+		if text, ok := block.(*types.ContentBlockMemberText); ok {
+			aiResponse += text.Value
+		}
+	}
+
+	return aiResponse, nil
+}
+
+// invoke calls an Anthropic model with the Messages API body through InvokeModel
+func (a *bedrock) invoke(ctx context.Context, bedrockClient *bedrockruntime.Client, message string) (string, error) {
 	claudeReq := claudeRequest{
 		AnthropicVersion: "bedrock-2023-05-31",
-		MaxTokens:        4096,
+		MaxTokens:        a.settings.maxTokens,
+		System:           a.settings.systemPrompt,
+		Temperature:      a.settings.temperature,
 		Messages: []claudeMessage{
 			{
 				Role:    "user",
@@ -91,41 +304,26 @@ func (a *bedrock) AnalyzeCode(fileName string) error {
 		},
 	}
 
-	// Convert the request to JSON
 	reqBody, err := json.Marshal(claudeReq)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Set a timeout for the API call
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	// Call the Amazon Bedrock API with Claude model (which powers Amazon Q)
-	// You can use different model IDs based on your needs
-	// modelID := "anthropic.claude-3-sonnet-20240229-v1:0"
-	modelID := "anthropic.claude-v2"
-
-	fmt.Println("executing Badrock command")
 	invokeResp, err := bedrockClient.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
-		ModelId:     aws.String(modelID),
+		ModelId:     aws.String(a.settings.modelID),
 		ContentType: aws.String("application/json"),
 		Body:        reqBody,
 	})
-
 	if err != nil {
-		return fmt.Errorf("failed to get response from Amazon Bedrock: %w", err)
+		return "", fmt.Errorf("failed to get response from Amazon Bedrock: %w", err)
 	}
-	fmt.Println("executed Badrock command")
 
-	// Parse the response
 	var claudeResp claudeResponse
 	err = json.Unmarshal(invokeResp.Body, &claudeResp)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal response: %w", err)
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	// Extract the response text
 	aiResponse := ""
 	for _, content := range claudeResp.Content {
 		if content.Type == "text" {
@@ -133,18 +331,5 @@ func (a *bedrock) AnalyzeCode(fileName string) error {
 		}
 	}
 
-	// Handle PR comments if needed
-	if a.commentOnPR {
-		err = commentOnPRIfNecessary(fileName, aiResponse, content.DiffContent, lineMap)
-		if err != nil {
-			return err
-		}
-	}
-
-	return generateReports(a.reporters, fileName, aiResponse)
-}
-
-// Summary implements Reviewer.
-func (a *bedrock) Summary() error {
-	return summary(a.reporters)
+	return aiResponse, nil
 }
@@ -0,0 +1,136 @@
+package review
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TokenBudget limits the estimated size of a single model request
+type TokenBudget struct {
+	MaxInputTokens int     // 0 means unlimited
+	CharsPerToken  float64 // Average characters per token of the model tokenizer
+}
+
+// Estimate returns the estimated number of tokens of the text
+func (b TokenBudget) Estimate(text string) int {
+	charsPerToken := b.CharsPerToken
+	if charsPerToken <= 0 {
+		charsPerToken = 4
+	}
+
+	return int(float64(len(text))/charsPerToken) + 1
+}
+
+// budgeted is implemented by the backends which know the context window of their model
+type budgeted interface {
+	tokenBudget() TokenBudget
+}
+
+// budgetOf returns the budget of the backend, maxInputTokens overrides the backend default if set
+func budgetOf(backend Model, maxInputTokens int) TokenBudget {
+	budget := TokenBudget{CharsPerToken: 4}
+	if b, ok := backend.(budgeted); ok {
+		budget = b.tokenBudget()
+	}
+
+	if maxInputTokens > 0 {
+		budget.MaxInputTokens = maxInputTokens
+	}
+
+	return budget
+}
+
+// chunk is a part of the line remapped content, offset is the number of lines before it
+type chunk struct {
+	offset  int
+	content string
+}
+
+// Lines starting a new top level declaration, or a diff hunk, these are the preferred places to split
+var chunkBoundaryRegex = regexp.MustCompile(`^(@@ |func |def |class |function |fn |pub |impl |type |interface |struct |enum |module |package |namespace |public |private |protected |static |export |async |const |var |let )`)
+
+// splitIntoChunks splits the content into chunks, which fit into the budget together with the prompt.
+// It splits on function and hunk boundaries where possible, returns false if the content cannot fit into maxChunks
+func splitIntoChunks(content, prompt string, budget TokenBudget, maxChunks int) ([]chunk, bool) {
+	if budget.MaxInputTokens <= 0 || budget.Estimate(prompt+content) <= budget.MaxInputTokens {
+		return []chunk{{offset: 0, content: content}}, true
+	}
+
+	available := budget.MaxInputTokens - budget.Estimate(prompt)
+	if available <= 0 {
+		return nil, false
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var chunks []chunk
+	var current strings.Builder
+	currentOffset := 0
+	flush := func(nextOffset int) {
+		if current.Len() > 0 {
+			chunks = append(chunks, chunk{offset: currentOffset, content: current.String()})
+			current.Reset()
+		}
+		currentOffset = nextOffset
+	}
+
+	for _, segment := range splitIntoSegments(lines) {
+		segmentContent := strings.Join(segment.lines, "")
+		if budget.Estimate(current.String()+segmentContent) <= available {
+			current.WriteString(segmentContent)
+			continue
+		}
+
+		flush(segment.offset)
+		if budget.Estimate(segmentContent) <= available {
+			current.WriteString(segmentContent)
+			continue
+		}
+
+		// A single function which does not fit is split by lines
+		for i, line := range segment.lines {
+			if budget.Estimate(line) > available {
+				return nil, false
+			}
+
+			if budget.Estimate(current.String()+line) > available {
+				flush(segment.offset + i)
+			}
+			current.WriteString(line)
+		}
+	}
+	flush(0)
+
+	if maxChunks > 0 && len(chunks) > maxChunks {
+		return nil, false
+	}
+
+	return chunks, true
+}
+
+type segment struct {
+	offset int
+	lines  []string
+}
+
+// splitIntoSegments groups the lines, each group starts on a boundary line
+func splitIntoSegments(lines []string) []segment {
+	var segments []segment
+	current := segment{}
+	for i, line := range lines {
+		if i > 0 && chunkBoundaryRegex.MatchString(line) {
+			segments = append(segments, current)
+			current = segment{offset: i}
+		}
+		current.lines = append(current.lines, line)
+	}
+
+	if len(current.lines) > 0 {
+		segments = append(segments, current)
+	}
+
+	return segments
+}
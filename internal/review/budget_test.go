@@ -0,0 +1,60 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/env"
+)
+
+func TestBedrockTokenBudgetIsNeverUnlimited(t *testing.T) {
+	for _, settings := range []bedrockSettings{
+		{modelID: "anthropic.claude-v2", maxTokens: 100000},
+		{modelID: "meta.llama3-1-70b-instruct-v1:0", maxTokens: 200000},
+	} {
+		budget := (&bedrock{settings: settings, contextWindow: bedrockContextWindow(settings.modelID)}).tokenBudget()
+		if budget.MaxInputTokens != bedrockMinInputTokens {
+			t.Errorf("got %d input tokens for max tokens %d of %s", budget.MaxInputTokens, settings.maxTokens, settings.modelID)
+		}
+	}
+}
+
+func TestBedrockContextWindowByModelID(t *testing.T) {
+	tests := []struct {
+		modelID string
+		want    int
+	}{
+		{"anthropic.claude-3-5-sonnet-20240620-v1:0", 100000},
+		{"meta.llama3-70b-instruct-v1:0", 8192},
+		{"meta.llama3-1-70b-instruct-v1:0", 128000}, // The longer prefix wins
+		{"mistral.mistral-large-2402-v1:0", 32000},
+		{"amazon.titan-text-lite-v1", 4096},
+		{"unknown.model", bedrockDefaultContextWindow},
+	}
+
+	for _, test := range tests {
+		if got := bedrockContextWindow(test.modelID); got != test.want {
+			t.Errorf("got context window %d of %s, want %d", got, test.modelID, test.want)
+		}
+	}
+}
+
+func TestBedrockTokenBudgetFitsSmallContextWindows(t *testing.T) {
+	// Half of the window is left for the input if the answer would take all of it
+	settings := bedrockSettings{modelID: "amazon.titan-text-lite-v1", maxTokens: 4096}
+	budget := (&bedrock{settings: settings, contextWindow: bedrockContextWindow(settings.modelID)}).tokenBudget()
+	if budget.MaxInputTokens != 2000 {
+		t.Errorf("got %d input tokens, want 2000", budget.MaxInputTokens)
+	}
+
+	t.Setenv(env.EnvBedrockContext, "16000")
+	t.Setenv(env.EnvBedrockModelID, "unknown.model")
+	t.Setenv(env.EnvBedrockMaxTokens, "1000")
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := budgetOf(newBedrock(envManager, ModelOptions{}), 0); got.MaxInputTokens != 15000 {
+		t.Errorf("got %d input tokens, want the configured context window less the answer", got.MaxInputTokens)
+	}
+}
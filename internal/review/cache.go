@@ -0,0 +1,71 @@
+package review
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/olbrichattila/qreview/internal/cache"
+)
+
+// withCache wraps the model, responses of identical requests to the same backend, model and settings are served from the cache
+func withCache(model Model, responseCache cache.Cache, identity string) Model {
+	if responseCache == nil {
+		return model
+	}
+
+	return &cachedModel{
+		model:    model,
+		cache:    responseCache,
+		identity: identity,
+	}
+}
+
+type cachedModel struct {
+	model    Model
+	cache    cache.Cache
+	identity string
+}
+
+// Complete implements Model.
+func (c *cachedModel) Complete(ctx context.Context, request Request) (Response, error) {
+	key := cache.Key(c.identity, request.Prompt, request.Content)
+	if text, ok := c.cache.Get(key); ok {
+		fmt.Printf("using cached AI response of %s\n", c.identity)
+		return Response{Text: text}, nil
+	}
+
+	response, err := c.model.Complete(ctx, request)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if err := c.cache.Set(key, response.Text); err != nil {
+		// A broken cache should not break the review
+		fmt.Printf("could not cache AI response: %s\n", err)
+	}
+
+	return response, nil
+}
+
+// cacheIdentified is implemented by the backends whose answers depend on settings beyond the model name
+type cacheIdentified interface {
+	cacheIdentity() string
+}
+
+// cacheIdentity returns the identity of the model in the cache keys, a change of the settings misses the cache
+func cacheIdentity(model Model) string {
+	if identified, ok := model.(cacheIdentified); ok {
+		return identified.cacheIdentity()
+	}
+
+	return identify(model)
+}
+
+// identify returns the backend and model name of the model, used to tell apart the cached responses
+func identify(model Model) string {
+	if stringer, ok := model.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+
+	return fmt.Sprintf("%T", model)
+}
@@ -0,0 +1,31 @@
+package review
+
+import "testing"
+
+func TestCacheIdentityChangesWithTheSettings(t *testing.T) {
+	temperature := 0.2
+	base := &bedrock{settings: bedrockSettings{modelID: "anthropic.claude", maxTokens: 4096, temperature: &temperature}}
+
+	otherTemperature := 0.7
+	changed := []*bedrock{
+		{settings: bedrockSettings{modelID: "anthropic.claude", maxTokens: 2048, temperature: &temperature}},
+		{settings: bedrockSettings{modelID: "anthropic.claude", maxTokens: 4096, temperature: &otherTemperature}},
+		{settings: bedrockSettings{modelID: "anthropic.claude", maxTokens: 4096, temperature: &temperature, systemPrompt: "Be brief"}},
+	}
+
+	for _, model := range changed {
+		if cacheIdentity(model) == cacheIdentity(base) {
+			t.Errorf("got the same identity for %+v", model.settings)
+		}
+	}
+
+	same := &bedrock{settings: bedrockSettings{modelID: "anthropic.claude", maxTokens: 4096, temperature: &temperature, timeout: 1}}
+	if cacheIdentity(same) != cacheIdentity(base) {
+		t.Error("got a new identity for a timeout change")
+	}
+
+	if cacheIdentity(&ollama{model: "llama3", options: ollamaOptions{NumCtx: 4096}}) ==
+		cacheIdentity(&ollama{model: "llama3", options: ollamaOptions{NumCtx: 8192}}) {
+		t.Error("got the same identity for another context window")
+	}
+}
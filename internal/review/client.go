@@ -3,10 +3,14 @@ package review
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/olbrichattila/qreview/internal/cache"
 	cmdinterpreter "github.com/olbrichattila/qreview/internal/cmd-interpreter"
 	"github.com/olbrichattila/qreview/internal/diffmapper"
 	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
 	"github.com/olbrichattila/qreview/internal/prcomment"
 	"github.com/olbrichattila/qreview/internal/report"
 	"github.com/olbrichattila/qreview/internal/retriever"
@@ -14,56 +18,192 @@ import (
 )
 
 const (
-	PromptReview         = "Review this code only for bugs and bad practices. Only comment issues and not good practices. Refer to the exact line number in the file. Use the following format for your comments: Line: <line number>: <review> Code starts here: "
+	PromptReview         = "Review this code only for bugs and bad practices. Only comment issues and not good practices. Refer to the exact line number in the file. Use the following format for your comments: Line: <line number>: [<severity>] <review>, where severity is one of info, minor, major, critical. Code starts here: "
 	PromptExplainChanges = "Explain changes of the following diff:\n\n"
 	PromptExplainCode    = "Explain what this code do:\n\n"
 
 	clientQ       = "amazon_q"
 	clientBedrock = "bedrock"
 	clientOllama  = "ollama"
+	clientOpenAI  = "openai"
 	clientMock    = "mock"
 )
 
-var prCommenterCache prcomment.Commenter
+// The commenter, the rate limiter and the response cache are created once and shared by all the reviewers
+var (
+	prCommenterCache prcomment.Commenter
+	prCommenterOnce  sync.Once
+	rateLimiterCache *rateLimiter
+	rateLimiterOnce  sync.Once
+	responseCache    cache.Cache
+	responseOnce     sync.Once
+)
+
+// ModelOptions overrides the model settings of the environment for a single definition,
+// zero values keep the environment settings
+type ModelOptions struct {
+	ModelID      string
+	MaxTokens    int
+	Temperature  *float64
+	SystemPrompt string
+	Timeout      time.Duration
+	// MaxInputTokens overrides the context budget of the backend, larger files are reviewed in chunks
+	MaxInputTokens int
+}
 
 // Reviewer interface have to be implemented
+// Analyze is safe to call concurrently, Publish and Summary must be called in order, one at a time
 type Reviewer interface {
+	Name() string
+	// CommentsOnPR tells if the definition is a review commenting on the PR, not documentation or prose
+	CommentsOnPR() bool
 	AnalyzeCode(filename string) error
+	Analyze(fileName string) (Analysis, error)
+	Publish(analysis Analysis) error
 	Summary() error
 }
 
+// Analysis is the model answer for a file, waiting to be commented and reported
+type Analysis struct {
+	FileName    string
+	Review      reviewparser.Response // Line numbers are mapped back to the lines of the file
+	DiffContent string
+	Reviewed    map[int]bool // Lines of the file sent to the model, blank lines are left out
+	Skipped     bool         // The file was too large for the model
+	Started     time.Time
+	Duration    time.Duration // Time spent on retrieving and reviewing the file
+}
+
+// OutputFormat is the format the model is asked to answer in
+type OutputFormat string
+
+const (
+	OutputText OutputFormat = "text" // Free text, line comments in the Line: <line number>: format
+	OutputJSON OutputFormat = "json" // JSON findings, validated and repaired if malformed
+)
+
+// OutputFormats are the supported output formats
+var OutputFormats = []OutputFormat{OutputText, OutputJSON}
+
 func New(
 	env env.EnvironmentManager,
+	name string,
 	retr retriever.Retriever,
 	reporters []report.Reporter,
 	prompt string,
 	commentOnPR bool,
+	modelOptions ModelOptions,
+	outputFormat OutputFormat,
 ) Reviewer {
-	envName := env.Client()
-	// TODO error handling properly
-	commenter, err := prcomment.New(env)
-	if err == nil {
-		prCommenterCache = commenter
+	prCommenterOnce.Do(func() {
+		kind, _, ok, err := pr.FromCommandLine(env)
+		if !ok || err != nil {
+			return
+		}
+
+		// TODO error handling properly
+		commenter, err := prcomment.New(env, kind)
+		if err == nil {
+			prCommenterCache = commenter
+		}
+	})
+
+	backend := newModel(env, modelOptions)
+	model := wrap(env, backend)
+
+	maxInputTokens := env.MaxInputTokens()
+	if modelOptions.MaxInputTokens > 0 {
+		maxInputTokens = modelOptions.MaxInputTokens
 	}
 
-	switch envName {
+	return NewPipeline(
+		name,
+		model,
+		retr,
+		prompt,
+		reporters,
+		commentOnPR,
+		budgetOf(backend, maxInputTokens),
+		env.MaxChunks(),
+		outputFormat,
+		describe(backend),
+	)
+}
+
+// NewModel returns the model of the configured AI client with the environment settings,
+// for asking the model outside of a review definition
+func NewModel(env env.EnvironmentManager) Model {
+	return wrap(env, newModel(env, ModelOptions{}))
+}
+
+// wrap adds the retry, the shared rate limiter and the response cache to the backend
+func wrap(env env.EnvironmentManager, backend Model) Model {
+	rateLimiterOnce.Do(func() {
+		rateLimiterCache = newRateLimiter(env.AIRequestsPerMinute(), env.AITokensPerMinute())
+	})
+
+	responseOnce.Do(func() {
+		if env.NoCache() || cmdinterpreter.HasFlag(cmdinterpreter.FlagNoCache) {
+			return
+		}
+
+		responseCache = cache.NewDisk(
+			env.CacheDir(),
+			time.Duration(env.CacheTTL())*time.Hour,
+			int64(env.CacheMaxSize())*1024*1024,
+		)
+	})
+
+	model := withRetry(
+		withRateLimit(backend, rateLimiterCache),
+		RetryPolicy{
+			MaxAttempts: env.AIMaxAttempts(),
+			BaseDelay:   time.Duration(env.AIRetryBaseDelay()) * time.Millisecond,
+			MaxDelay:    time.Duration(env.AIRetryMaxDelay()) * time.Millisecond,
+		},
+	)
+
+	return withCache(model, responseCache, cacheIdentity(backend))
+}
+
+// CheckModelOptions tells if the configured AI client can apply the model overrides of a definition.
+// The Amazon Q CLI has no model settings, only the input budget applies to it
+func CheckModelOptions(env env.EnvironmentManager, modelOptions ModelOptions) error {
+	switch env.Client() {
+	case clientBedrock, clientOllama, clientOpenAI, clientMock:
+		return nil
+	}
+
+	budgetOnly := ModelOptions{MaxInputTokens: modelOptions.MaxInputTokens}
+	if modelOptions != budgetOnly {
+		return fmt.Errorf("the %s client has no model settings, only maxInputTokens can be set", clientQ)
+	}
+
+	return nil
+}
+
+// newModel returns the model of the configured AI client
+func newModel(env env.EnvironmentManager, modelOptions ModelOptions) Model {
+	switch env.Client() {
 	case clientQ:
-		return newAws(retr, prompt, reporters, commentOnPR)
+		return newAws()
 	case clientBedrock:
-		return newBedrock(retr, prompt, reporters, commentOnPR)
+		return newBedrock(env, modelOptions)
 	case clientOllama:
-		return newOllama(retr, prompt, reporters, commentOnPR)
+		return newOllama(env, modelOptions)
+	case clientOpenAI:
+		return newOpenAI(env, modelOptions)
 	case clientMock:
-		return newMock(retr, prompt, reporters, commentOnPR)
+		return newMock()
 	default:
-		return newAws(retr, prompt, reporters, commentOnPR)
+		return newAws()
 	}
 }
 
-func generateReports(reporters []report.Reporter, fileName, mdContent string) error {
+func generateReports(reporters []report.Reporter, entry report.Entry) error {
 	for _, reporter := range reporters {
 		if reporter != nil {
-			if err := reporter.Report(fileName, mdContent); err != nil {
+			if err := reporter.Report(entry); err != nil {
 				return err
 			}
 		}
@@ -84,38 +224,55 @@ func summary(reporters []report.Reporter) error {
 	return nil
 }
 
-func commentOnPRIfNecessary(filePath string, comment, diffContent string, lineMap map[int]int) error {
-	if cmdinterpreter.HasFlag(cmdinterpreter.FlagGithubPR) &&
+// SubmitPRComments publishes the PR comments collected during the run, as a single review where the forge supports it.
+// The review requests changes if a finding is at requestChangesOn severity or above
+func SubmitPRComments(requestChangesOn string) error {
+	prURL, isPR := pr.URLFromCommandLine()
+	if !isPR || !cmdinterpreter.HasFlag(cmdinterpreter.FlagComment) || prCommenterCache == nil {
+		return nil
+	}
+
+	return prCommenterCache.Submit(prURL, requestChangesOn)
+}
+
+func commentOnPRIfNecessary(definition, filePath string, review reviewparser.Response, diffContent string, reviewed map[int]bool) error {
+	prURL, isPR := pr.URLFromCommandLine()
+	if isPR &&
 		cmdinterpreter.HasFlag(cmdinterpreter.FlagComment) &&
 		prCommenterCache != nil {
+		prCommenterCache.Reviewed(definition, filePath)
+
+		var err error
 		remap := false
 		var diffMap diffmapper.ChangedLines
 		if diffContent != "" {
 			diffMap = diffmapper.GetMap(diffContent)
 			remap = true
 		}
-		prURL, err := cmdinterpreter.Flag(cmdinterpreter.FlagGithubPR)
-		if err != nil {
-			return err
-		}
-
-		parsedReview := reviewparser.Parse(comment)
 
 		defaultLine := 1
 		// This might rather be an error?
-		if diffMap != nil {
+		if len(diffMap) > 0 {
 			defaultLine = diffMap[0].LineNum
 		}
 
-		fmt.Printf("Commenting on PR File: %s, line %d\n", filePath, defaultLine)
-		err = prCommenterCache.Comment(prURL, filePath, parsedReview.Summary, defaultLine)
-		if err != nil {
-			return err
+		if review.Summary != "" {
+			fmt.Printf("Commenting on PR File: %s, line %d\n", filePath, defaultLine)
+			err = prCommenterCache.Comment(prURL, prcomment.Comment{
+				Definition: definition,
+				FilePath:   filePath,
+				Line:       defaultLine,
+				Finding:    reviewparser.Finding{File: filePath, Message: review.Summary},
+			})
+			if err != nil {
+				return err
+			}
 		}
-		for lineNr, lineComment := range parsedReview.Lines {
-			mappedLineNr := lineMap[lineNr]
+
+		for _, finding := range review.Findings {
+			startLineNr, lineNr := finding.StartLine, max(finding.EndLine, finding.StartLine)
 			if remap {
-				mappedLineNr, err = diffmapper.GetClosestPrOffset(mappedLineNr)
+				startLineNr, lineNr, err = diffMap.ClosestPrRange(startLineNr, lineNr)
 				if err != nil {
 					// skip for now
 					continue
@@ -123,12 +280,27 @@ func commentOnPRIfNecessary(filePath string, comment, diffContent string, lineMa
 				}
 			}
 
-			fmt.Printf("Commenting on PR File: %s, line %d\n", filePath, mappedLineNr)
-			err = prCommenterCache.Comment(prURL, filePath, lineComment, mappedLineNr)
+			comment := prcomment.Comment{
+				Definition: definition,
+				FilePath:   filePath,
+				Line:       lineNr,
+				Finding:    finding,
+				Reviewed:   reviewed,
+			}
+
+			if startLineNr < lineNr {
+				comment.StartLine = startLineNr
+				fmt.Printf("Commenting on PR File: %s, lines %d-%d\n", filePath, startLineNr, lineNr)
+			} else {
+				fmt.Printf("Commenting on PR File: %s, line %d\n", filePath, lineNr)
+			}
+
+			err = prCommenterCache.Comment(prURL, comment)
 			if err != nil {
 				return err
 			}
 		}
+
 	}
 
 	return nil
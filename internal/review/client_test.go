@@ -0,0 +1,35 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/env"
+)
+
+func TestCheckModelOptionsRejectsOverridesOfTheQCLI(t *testing.T) {
+	temperature := 0.2
+	tests := []struct {
+		client       string
+		modelOptions ModelOptions
+		wantErr      bool
+	}{
+		{clientQ, ModelOptions{}, false},
+		{clientQ, ModelOptions{MaxInputTokens: 8000}, false},
+		{clientQ, ModelOptions{ModelID: "anthropic.claude-v2"}, true},
+		{clientQ, ModelOptions{Temperature: &temperature}, true},
+		{clientOllama, ModelOptions{ModelID: "llama3", Temperature: &temperature}, false},
+		{clientOpenAI, ModelOptions{SystemPrompt: "Be brief."}, false},
+	}
+
+	for _, test := range tests {
+		t.Setenv(env.EnvAIClient, test.client)
+		envManager, err := env.NewDotEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := CheckModelOptions(envManager, test.modelOptions); (err != nil) != test.wantErr {
+			t.Errorf("got error %v for %s with %+v", err, test.client, test.modelOptions)
+		}
+	}
+}
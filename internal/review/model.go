@@ -0,0 +1,61 @@
+package review
+
+import (
+	"context"
+	"time"
+)
+
+// Request is what the pipeline sends to a model
+type Request struct {
+	Prompt  string // The instruction from the definition
+	Content string // The retrieved, line remapped code
+}
+
+// Message returns the single user message built from the prompt and the content
+func (r Request) Message() string {
+	return r.Prompt + r.Content
+}
+
+// Response is the answer of the model
+type Response struct {
+	Text string
+}
+
+// Model is implemented by each AI backend, it is only a transport to the model,
+// retrieving, line remapping, commenting and reporting is done by the Pipeline
+type Model interface {
+	Complete(ctx context.Context, request Request) (Response, error)
+}
+
+// Identity is the backend and the model name behind a Model, shown in the machine readable reports
+type Identity struct {
+	Backend string
+	Model   string
+}
+
+// describe returns the identity of a backend, the model is empty if the backend does not tell it
+func describe(model Model) Identity {
+	switch m := model.(type) {
+	case *awsq:
+		return Identity{Backend: clientQ}
+	case *bedrock:
+		return Identity{Backend: clientBedrock, Model: m.settings.modelID}
+	case *ollama:
+		return Identity{Backend: clientOllama, Model: m.model}
+	case *openAI:
+		return Identity{Backend: clientOpenAI, Model: m.model}
+	case *mock:
+		return Identity{Backend: clientMock}
+	default:
+		return Identity{Backend: identify(model)}
+	}
+}
+
+// withTimeout limits the model call to the timeout of the backend, zero or less means no timeout
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
@@ -2,91 +2,177 @@ package review
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
-	"os/exec"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/olbrichattila/qreview/internal/helpers"
-	"github.com/olbrichattila/qreview/internal/report"
-	"github.com/olbrichattila/qreview/internal/retriever"
+	"github.com/olbrichattila/qreview/internal/cache"
+	"github.com/olbrichattila/qreview/internal/env"
 )
 
 /*
-	This is the ollama client impelentation. On linux install:
+	This is the ollama client implementation, it talks to the Ollama REST API. On linux install:
 	curl -fsSL https://ollama.com/install.sh | sh
 	Pull a model like:
 	ollama pull llama3
 
-	Example usage: ollama run llama3 "What's the capital of France?"
-
+	The API is served on http://localhost:11434 by default, a remote (GPU) host
+	can be used by setting OLLAMA_HOST, the model by setting OLLAMA_MODEL.
 */
 
-// NewOllama creates a new mock reviewer
-func newOllama(
-	retr retriever.Retriever,
-	prompt string,
-	reporters []report.Reporter,
-	commentOnPR bool,
-) Reviewer {
-	return &ollama{
-		reporters:   reporters,
-		retr:        retr,
-		prompt:      prompt,
-		commentOnPR: commentOnPR,
+// newOllama creates a new Ollama model using the Ollama REST API, the definition overrides the environment settings
+func newOllama(env env.EnvironmentManager, modelOptions ModelOptions) Model {
+	model := &ollama{
+		baseURL: env.OllamaHost(),
+		model:   env.OllamaModel(),
+		options: ollamaOptions{
+			Temperature: env.OllamaTemperature(),
+			NumCtx:      env.OllamaNumCtx(),
+		},
+		timeout:    time.Duration(env.OllamaTimeout()) * time.Second,
+		httpClient: &http.Client{},
+	}
+
+	if modelOptions.ModelID != "" {
+		model.model = modelOptions.ModelID
+	}
+
+	if modelOptions.MaxTokens > 0 {
+		model.options.NumPredict = modelOptions.MaxTokens
+	}
+
+	if modelOptions.Temperature != nil {
+		model.options.Temperature = *modelOptions.Temperature
+	}
+
+	if modelOptions.Timeout > 0 {
+		model.timeout = modelOptions.Timeout
 	}
+
+	model.system = modelOptions.SystemPrompt
+
+	return model
 }
 
 type ollama struct {
-	reporters   []report.Reporter
-	retr        retriever.Retriever
-	prompt      string
-	commentOnPR bool
+	baseURL    string
+	model      string
+	system     string // Overrides the system prompt of the model if set
+	options    ollamaOptions
+	timeout    time.Duration
+	httpClient *http.Client
 }
 
-// AnalyzeCode returns the result of the analyzed code
-func (a *ollama) AnalyzeCode(fileName string) error {
-	var err error
-	content, err := a.retr.Get(fileName)
-	if err != nil {
-		return fmt.Errorf("Analyze code %w", err)
+// String returns the backend and model name
+func (a *ollama) String() string {
+	return fmt.Sprintf("%s/%s/%s", clientOllama, a.baseURL, a.model)
+}
+
+// cacheIdentity implements cacheIdentified, the model parameters are hashed
+func (a *ollama) cacheIdentity() string {
+	return a.String() + "/" + cache.Key(
+		strconv.FormatFloat(a.options.Temperature, 'g', -1, 64),
+		strconv.Itoa(a.options.NumCtx),
+		strconv.Itoa(a.options.NumPredict),
+		a.system,
+	)
+}
+
+// tokenBudget implements budgeted, a quarter of the context window is left for the answer
+func (a *ollama) tokenBudget() TokenBudget {
+	numCtx := a.options.NumCtx
+	if numCtx <= 0 {
+		// Ollama default context window
+		numCtx = 4096
 	}
 
-	remappedContent, lineMap := helpers.SourceCodeLineRemap(content.FileContent)
+	return TokenBudget{MaxInputTokens: numCtx * 3 / 4, CharsPerToken: 4}
+}
 
-	fmt.Println("executing ollama command")
-	cmd := exec.Command("ollama", "run", "llama3", a.prompt+remappedContent)
+// ollamaOptions are the model parameters, see https://github.com/ollama/ollama/blob/main/docs/modelfile.md#parameter
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumCtx      int     `json:"num_ctx,omitempty"`
+	NumPredict  int     `json:"num_predict,omitempty"` // Maximum number of tokens of the answer
+}
 
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err = cmd.Run()
+// ollamaRequest is the body of the /api/generate request
+type ollamaRequest struct {
+	Model   string        `json:"model"`
+	Prompt  string        `json:"prompt"`
+	System  string        `json:"system,omitempty"`
+	Stream  bool          `json:"stream"`
+	Options ollamaOptions `json:"options"`
+}
+
+// ollamaResponse is the non streamed response of /api/generate
+type ollamaResponse struct {
+	Model    string `json:"model"`
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Complete implements Model.
+func (a *ollama) Complete(ctx context.Context, request Request) (Response, error) {
+	fmt.Printf("executing ollama request, model %s\n", a.model)
+	aiResponse, err := a.generate(ctx, request.Message())
 	if err != nil {
-		return fmt.Errorf("cannot execute ollama command, %w", err)
+		return Response{}, err
 	}
-	fmt.Println("executed ollama command")
+	fmt.Println("executed ollama request")
 
-	aiResponse := out.String()
+	return Response{Text: aiResponse}, nil
+}
 
-	// FAKE cached AI response
-	// data, err := os.ReadFile("report/2025/05/05/10_09/changes/test.php.md")
-	// if err != nil {
-	// 	return err
-	// }
+// generate sends the prompt to the /api/generate endpoint and returns the generated text
+func (a *ollama) generate(ctx context.Context, prompt string) (string, error) {
+	reqBody, err := json.Marshal(ollamaRequest{
+		Model:   a.model,
+		Prompt:  prompt,
+		System:  a.system,
+		Stream:  false,
+		Options: a.options,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal ollama request: %w", err)
+	}
 
-	// aiResponse := string(data)
+	ctx, cancel := withTimeout(ctx, a.timeout)
+	defer cancel()
 
-	// todo do a PR analizer and comment if line is provided in the response
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/api/generate", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-	// TODO mapper
-	if a.commentOnPR {
-		err = commentOnPRIfNecessary(fileName, aiResponse, content.DiffContent, lineMap)
-		if err != nil {
-			return err
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot reach ollama at %s: %w", a.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read ollama response: %w", err)
+	}
+
+	var ollamaResp ollamaResponse
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", newStatusError(resp, fmt.Sprintf("ollama returned %s: %s", resp.Status, string(body)))
 		}
+		return "", fmt.Errorf("failed to unmarshal ollama response: %w", err)
 	}
-	return generateReports(a.reporters, fileName, aiResponse)
 
-}
+	if resp.StatusCode != http.StatusOK {
+		return "", newStatusError(resp, fmt.Sprintf("ollama returned %s: %s", resp.Status, ollamaResp.Error))
+	}
 
-// Summary implements Reviewer.
-func (a *ollama) Summary() error {
-	return summary(a.reporters)
+	return ollamaResp.Response, nil
 }
@@ -0,0 +1,138 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/env"
+)
+
+// newTestOllama creates the Ollama model of the environment and the definition overrides, talking to the handler
+func newTestOllama(t *testing.T, modelOptions ModelOptions, handler http.HandlerFunc) Model {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv(env.EnvOllamaHost, server.URL)
+	t.Setenv(env.EnvOllamaModel, "llama3")
+	t.Setenv(env.EnvOllamaTemperature, "0.3")
+	t.Setenv(env.EnvOllamaNumCtx, "8192")
+	t.Setenv(env.EnvOllamaTimeout, "5")
+
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newOllama(envManager, modelOptions)
+}
+
+func TestOllamaCompleteSendsGenerateRequest(t *testing.T) {
+	var got map[string]any
+	model := newTestOllama(t, ModelOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/generate" {
+			t.Errorf("got %s %s, want POST /api/generate", r.Method, r.URL.Path)
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %s", err)
+		}
+
+		w.Write([]byte(`{"model":"llama3","response":"Line: 3: [major] nil map","done":true}`))
+	})
+
+	response, err := model.Complete(context.Background(), Request{Prompt: "Review: ", Content: "package main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Text != "Line: 3: [major] nil map" {
+		t.Errorf("got response %q", response.Text)
+	}
+
+	if got["model"] != "llama3" || got["prompt"] != "Review: package main" || got["stream"] != false {
+		t.Errorf("got request %v", got)
+	}
+
+	options, _ := got["options"].(map[string]any)
+	if options["temperature"] != 0.3 || options["num_ctx"] != float64(8192) {
+		t.Errorf("got options %v", options)
+	}
+}
+
+func TestOllamaCompleteAppliesTheDefinitionOverrides(t *testing.T) {
+	var got map[string]any
+	temperature := 0.1
+	modelOptions := ModelOptions{ModelID: "qwen2.5-coder", MaxTokens: 512, Temperature: &temperature, SystemPrompt: "Be brief."}
+	model := newTestOllama(t, modelOptions, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %s", err)
+		}
+
+		w.Write([]byte(`{"response":"","done":true}`))
+	})
+
+	if _, err := model.Complete(context.Background(), Request{Prompt: "Review: "}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["model"] != "qwen2.5-coder" || got["system"] != "Be brief." {
+		t.Errorf("got request %v", got)
+	}
+
+	options, _ := got["options"].(map[string]any)
+	if options["temperature"] != 0.1 || options["num_predict"] != float64(512) || options["num_ctx"] != float64(8192) {
+		t.Errorf("got options %v", options)
+	}
+}
+
+func TestOllamaCompleteWithoutTimeout(t *testing.T) {
+	model := newTestOllama(t, ModelOptions{}, func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"response":"ok","done":true}`))
+	})
+
+	// OLLAMA_TIMEOUT=0 waits without a limit, the call does not expire at once
+	model.(*ollama).timeout = 0
+	if response, err := model.Complete(context.Background(), Request{Prompt: "Review: "}); err != nil || response.Text != "ok" {
+		t.Errorf("got %q, %v without a timeout", response.Text, err)
+	}
+}
+
+func TestOllamaCompleteReturnsStatusError(t *testing.T) {
+	model := newTestOllama(t, ModelOptions{}, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":"model is loading"}`))
+	})
+
+	_, err := model.Complete(context.Background(), Request{Prompt: "Review: ", Content: "package main"})
+
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("got error %v, want a status error", err)
+	}
+
+	if statusErr.StatusCode != http.StatusServiceUnavailable || statusErr.RetryAfter.Seconds() != 7 {
+		t.Errorf("got status %d, retry after %s", statusErr.StatusCode, statusErr.RetryAfter)
+	}
+
+	if !strings.Contains(statusErr.Message, "model is loading") {
+		t.Errorf("got message %q", statusErr.Message)
+	}
+}
+
+func TestOllamaCompleteRejectsInvalidResponse(t *testing.T) {
+	model := newTestOllama(t, ModelOptions{}, func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`not json`))
+	})
+
+	if _, err := model.Complete(context.Background(), Request{Prompt: "Review: "}); err == nil {
+		t.Fatal("got no error for an invalid response")
+	}
+}
@@ -0,0 +1,171 @@
+package review
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/cache"
+	"github.com/olbrichattila/qreview/internal/env"
+)
+
+/*
+	This client speaks the OpenAI /v1/chat/completions protocol, which is also exposed
+	by most self hosted gateways like vLLM, LiteLLM or LocalAI.
+	Point OPENAI_BASE_URL to the gateway, like http://localhost:8000/v1
+*/
+
+// newOpenAI creates a new model for OpenAI compatible chat completions APIs, the definition overrides the environment settings
+func newOpenAI(env env.EnvironmentManager, modelOptions ModelOptions) Model {
+	model := &openAI{
+		baseURL:      env.OpenAIBaseURL(),
+		apiKey:       env.OpenAIAPIKey(),
+		model:        env.OpenAIModel(),
+		maxTokens:    modelOptions.MaxTokens,
+		temperature:  modelOptions.Temperature,
+		systemPrompt: modelOptions.SystemPrompt,
+		timeout:      time.Duration(env.OpenAITimeout()) * time.Second,
+		httpClient:   &http.Client{},
+	}
+
+	if modelOptions.ModelID != "" {
+		model.model = modelOptions.ModelID
+	}
+
+	if modelOptions.Timeout > 0 {
+		model.timeout = modelOptions.Timeout
+	}
+
+	return model
+}
+
+type openAI struct {
+	baseURL      string
+	apiKey       string
+	model        string
+	maxTokens    int      // 0 leaves it to the API
+	temperature  *float64 // nil leaves it to the API
+	systemPrompt string
+	timeout      time.Duration
+	httpClient   *http.Client
+}
+
+// String returns the backend and model name
+func (a *openAI) String() string {
+	return fmt.Sprintf("%s/%s/%s", clientOpenAI, a.baseURL, a.model)
+}
+
+// cacheIdentity implements cacheIdentified, the settings shaping the answer are hashed
+func (a *openAI) cacheIdentity() string {
+	temperature := ""
+	if a.temperature != nil {
+		temperature = strconv.FormatFloat(*a.temperature, 'g', -1, 64)
+	}
+
+	return a.String() + "/" + cache.Key(strconv.Itoa(a.maxTokens), temperature, a.systemPrompt)
+}
+
+// tokenBudget implements budgeted, gateways serve models of any size, so it is a conservative default
+func (a *openAI) tokenBudget() TokenBudget {
+	return TokenBudget{MaxInputTokens: 32000, CharsPerToken: 4}
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Complete implements Model.
+func (a *openAI) Complete(ctx context.Context, request Request) (Response, error) {
+	fmt.Printf("executing chat completion request, model %s\n", a.model)
+	aiResponse, err := a.complete(ctx, request.Message())
+	if err != nil {
+		return Response{}, err
+	}
+	fmt.Println("executed chat completion request")
+
+	return Response{Text: aiResponse}, nil
+}
+
+// complete sends the prompt as a single user message, after the system prompt if set, and returns the first choice
+func (a *openAI) complete(ctx context.Context, prompt string) (string, error) {
+	var messages []chatMessage
+	if a.systemPrompt != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: a.systemPrompt})
+	}
+
+	reqBody, err := json.Marshal(chatCompletionRequest{
+		Model:       a.model,
+		Messages:    append(messages, chatMessage{Role: "user", Content: prompt}),
+		MaxTokens:   a.maxTokens,
+		Temperature: a.temperature,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal chat completion request: %w", err)
+	}
+
+	ctx, cancel := withTimeout(ctx, a.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/chat/completions", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot reach chat completions API at %s: %w", a.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read chat completion response: %w", err)
+	}
+
+	var chatResp chatCompletionResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", newStatusError(resp, fmt.Sprintf("chat completions API returned %s: %s", resp.Status, string(body)))
+		}
+		return "", fmt.Errorf("failed to unmarshal chat completion response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if chatResp.Error != nil {
+			return "", newStatusError(resp, fmt.Sprintf("chat completions API returned %s: %s", resp.Status, chatResp.Error.Message))
+		}
+		return "", newStatusError(resp, fmt.Sprintf("chat completions API returned %s", resp.Status))
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("chat completions API returned no choices")
+	}
+
+	return chatResp.Choices[0].Message.Content, nil
+}
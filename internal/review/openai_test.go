@@ -0,0 +1,153 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/env"
+)
+
+// newTestOpenAI creates the chat completions model of the environment and the definition overrides, talking to the handler
+func newTestOpenAI(t *testing.T, apiKey string, modelOptions ModelOptions, handler http.HandlerFunc) Model {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv(env.EnvOpenAIBaseURL, server.URL+"/v1")
+	t.Setenv(env.EnvOpenAIAPIKey, apiKey)
+	t.Setenv(env.EnvOpenAIModel, "gpt-4o-mini")
+	t.Setenv(env.EnvOpenAITimeout, "5")
+
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newOpenAI(envManager, modelOptions)
+}
+
+func TestOpenAICompleteSendsChatCompletionRequest(t *testing.T) {
+	var got chatCompletionRequest
+	var authorization string
+	model := newTestOpenAI(t, "sk-test", ModelOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("got %s %s, want POST /v1/chat/completions", r.Method, r.URL.Path)
+		}
+
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %s", err)
+		}
+
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Line: 3: [major] nil map"}}]}`))
+	})
+
+	response, err := model.Complete(context.Background(), Request{Prompt: "Review: ", Content: "package main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Text != "Line: 3: [major] nil map" {
+		t.Errorf("got response %q", response.Text)
+	}
+
+	if authorization != "Bearer sk-test" {
+		t.Errorf("got authorization %q", authorization)
+	}
+
+	if got.Model != "gpt-4o-mini" || len(got.Messages) != 1 || got.Messages[0] != (chatMessage{Role: "user", Content: "Review: package main"}) {
+		t.Errorf("got request %+v", got)
+	}
+
+	if got.MaxTokens != 0 || got.Temperature != nil {
+		t.Errorf("got max tokens %d, temperature %v, want them left to the API", got.MaxTokens, got.Temperature)
+	}
+}
+
+func TestOpenAICompleteAppliesTheDefinitionOverrides(t *testing.T) {
+	var got map[string]any
+	var authorized bool
+	temperature := 0.0
+	modelOptions := ModelOptions{ModelID: "gpt-4o", MaxTokens: 512, Temperature: &temperature, SystemPrompt: "Be brief."}
+	model := newTestOpenAI(t, "", modelOptions, func(w http.ResponseWriter, r *http.Request) {
+		_, authorized = r.Header["Authorization"]
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %s", err)
+		}
+
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	})
+
+	if _, err := model.Complete(context.Background(), Request{Prompt: "Review: "}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Local servers, like vLLM, need no key
+	if authorized {
+		t.Error("got an authorization header without an API key")
+	}
+
+	// A zero temperature is sent, it is not the API default
+	if got["model"] != "gpt-4o" || got["max_tokens"] != float64(512) || got["temperature"] != 0.0 {
+		t.Errorf("got request %v", got)
+	}
+
+	messages, _ := got["messages"].([]any)
+	if len(messages) != 2 {
+		t.Fatalf("got messages %v, want the system prompt and the user message", got["messages"])
+	}
+
+	if system, _ := messages[0].(map[string]any); system["role"] != "system" || system["content"] != "Be brief." {
+		t.Errorf("got first message %v", system)
+	}
+}
+
+func TestOpenAICompleteReturnsStatusError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		message string
+	}{
+		{"api error", http.StatusTooManyRequests, `{"error":{"message":"Rate limit reached","type":"requests"}}`, "Rate limit reached"},
+		{"not json", http.StatusBadGateway, `<html>Bad Gateway</html>`, "Bad Gateway"},
+		{"no error object", http.StatusUnauthorized, `{}`, "401"},
+	}
+
+	for _, test := range tests {
+		model := newTestOpenAI(t, "sk-test", ModelOptions{}, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		})
+
+		_, err := model.Complete(context.Background(), Request{Prompt: "Review: "})
+
+		var statusErr *statusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("%s: got error %v, want a status error", test.name, err)
+		}
+
+		if statusErr.StatusCode != test.status || statusErr.RetryAfter.Seconds() != 7 || !strings.Contains(statusErr.Message, test.message) {
+			t.Errorf("%s: got status %d, retry after %s, message %q", test.name, statusErr.StatusCode, statusErr.RetryAfter, statusErr.Message)
+		}
+	}
+}
+
+func TestOpenAICompleteRejectsInvalidResponses(t *testing.T) {
+	for _, body := range []string{`not json`, `{"choices":[]}`} {
+		model := newTestOpenAI(t, "sk-test", ModelOptions{}, func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(body))
+		})
+
+		if _, err := model.Complete(context.Background(), Request{Prompt: "Review: "}); err == nil {
+			t.Errorf("got no error for the response %s", body)
+		}
+	}
+}
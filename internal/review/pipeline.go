@@ -0,0 +1,218 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/helpers"
+	"github.com/olbrichattila/qreview/internal/report"
+	"github.com/olbrichattila/qreview/internal/retriever"
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+// NewPipeline creates a reviewer which retrieves the code, remaps the lines, asks the model,
+// then comments on the PR and generates the reports
+func NewPipeline(
+	name string,
+	model Model,
+	retr retriever.Retriever,
+	prompt string,
+	reporters []report.Reporter,
+	commentOnPR bool,
+	budget TokenBudget,
+	maxChunks int,
+	outputFormat OutputFormat,
+	identity Identity,
+) *Pipeline {
+	return &Pipeline{
+		name:        name,
+		model:       model,
+		retr:        retr,
+		prompt:      prompt,
+		reporters:   reporters,
+		commentOnPR: commentOnPR,
+		budget:      budget,
+		maxChunks:   maxChunks,
+		structured:  outputFormat == OutputJSON,
+		identity:    identity,
+	}
+}
+
+// Pipeline owns the review steps shared by every backend
+type Pipeline struct {
+	name        string
+	model       Model
+	retr        retriever.Retriever
+	prompt      string
+	reporters   []report.Reporter
+	commentOnPR bool
+	budget      TokenBudget
+	maxChunks   int
+	structured  bool
+	identity    Identity
+}
+
+// Name implements Reviewer.
+func (p *Pipeline) Name() string {
+	return p.name
+}
+
+// CommentsOnPR implements Reviewer.
+func (p *Pipeline) CommentsOnPR() bool {
+	return p.commentOnPR
+}
+
+// AnalyzeCode implements Reviewer.
+func (p *Pipeline) AnalyzeCode(fileName string) error {
+	analysis, err := p.Analyze(fileName)
+	if err != nil {
+		return err
+	}
+
+	return p.Publish(analysis)
+}
+
+// Analyze implements Reviewer.
+func (p *Pipeline) Analyze(fileName string) (Analysis, error) {
+	started := time.Now()
+	content, err := p.retr.Get(fileName)
+	if err != nil {
+		return Analysis{}, fmt.Errorf("Analyze code %w", err)
+	}
+
+	remappedContent, lineMap := helpers.SourceCodeLineRemap(content.FileContent)
+
+	prompt := p.prompt
+	if p.structured {
+		prompt += "\n\n" + reviewparser.JSONInstructions + "\nCode starts here:\n"
+	}
+
+	chunks, ok := splitIntoChunks(remappedContent, prompt, p.budget, p.maxChunks)
+	if !ok {
+		fmt.Printf("Skipping %s (%s), it does not fit into the model context\n", fileName, p.name)
+		skipped := fmt.Sprintf(
+			"**Skipped:** the file does not fit into the model context of %d tokens in %d chunks, it was not reviewed.\n",
+			p.budget.MaxInputTokens,
+			p.maxChunks,
+		)
+
+		return Analysis{
+			FileName: fileName,
+			Review:   reviewparser.Response{Summary: skipped, Raw: skipped},
+			Skipped:  true,
+			Started:  started,
+			Duration: time.Since(started),
+		}, nil
+	}
+
+	reviews := make([]reviewparser.Response, 0, len(chunks))
+	for i, c := range chunks {
+		if len(chunks) > 1 {
+			fmt.Printf("Reviewing chunk %d/%d of %s (%s)\n", i+1, len(chunks), fileName, p.name)
+		}
+
+		review, err := p.review(fileName, prompt, c.content)
+		if err != nil {
+			return Analysis{}, err
+		}
+
+		// The model counts the lines from the beginning of the chunk
+		reviews = append(reviews, review.Shift(c.offset))
+	}
+
+	return Analysis{
+		FileName:    fileName,
+		Review:      reviewparser.Merge(reviews).MapLines(originalLine(lineMap)),
+		DiffContent: content.DiffContent,
+		Reviewed:    reviewedLines(lineMap),
+		Started:     started,
+		Duration:    time.Since(started),
+	}, nil
+}
+
+// review asks the model and parses the answer. Structured answers which are not valid are sent back
+// once to be repaired, if it does not help the answer is parsed as free text
+func (p *Pipeline) review(fileName, prompt, content string) (reviewparser.Response, error) {
+	response, err := p.model.Complete(context.Background(), Request{
+		Prompt:  prompt,
+		Content: content,
+	})
+	if err != nil {
+		return reviewparser.Response{}, err
+	}
+
+	if !p.structured {
+		return reviewparser.Parse(response.Text), nil
+	}
+
+	review, parseErr := reviewparser.ParseJSON(response.Text, fileName)
+	if parseErr == nil {
+		return review, nil
+	}
+
+	fmt.Printf("Invalid JSON review of %s (%s): %s, asking the model to repair it\n", fileName, p.name, parseErr)
+	repaired, err := p.model.Complete(context.Background(), Request{
+		Prompt:  reviewparser.RepairPrompt(parseErr),
+		Content: response.Text,
+	})
+	if err != nil {
+		return reviewparser.Response{}, err
+	}
+
+	review, parseErr = reviewparser.ParseJSON(repaired.Text, fileName)
+	if parseErr == nil {
+		return review, nil
+	}
+
+	fmt.Printf("Repaired JSON review of %s (%s) is still invalid: %s, parsing it as text\n", fileName, p.name, parseErr)
+	return reviewparser.Parse(response.Text), nil
+}
+
+// Publish implements Reviewer.
+func (p *Pipeline) Publish(analysis Analysis) error {
+	if p.commentOnPR && !analysis.Skipped {
+		err := commentOnPRIfNecessary(p.name, analysis.FileName, analysis.Review, analysis.DiffContent, analysis.Reviewed)
+		if err != nil {
+			return err
+		}
+	}
+
+	return generateReports(p.reporters, report.Entry{
+		FileName:   analysis.FileName,
+		Review:     analysis.Review,
+		Definition: p.name,
+		Prompt:     p.prompt,
+		Backend:    p.identity.Backend,
+		Model:      p.identity.Model,
+		Skipped:    analysis.Skipped,
+		Started:    analysis.Started,
+		Duration:   analysis.Duration,
+	})
+}
+
+// originalLine maps the 1 based line numbers of the line remapped content back to the lines of the file
+func originalLine(lineMap map[int]int) func(int) int {
+	return func(lineNr int) int {
+		if original, ok := lineMap[lineNr-1]; ok {
+			return original
+		}
+
+		return lineNr
+	}
+}
+
+// reviewedLines returns the lines of the file in the line remapped content
+func reviewedLines(lineMap map[int]int) map[int]bool {
+	reviewed := make(map[int]bool, len(lineMap))
+	for _, original := range lineMap {
+		reviewed[original] = true
+	}
+
+	return reviewed
+}
+
+// Summary implements Reviewer.
+func (p *Pipeline) Summary() error {
+	return summary(p.reporters)
+}
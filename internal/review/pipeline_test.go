@@ -0,0 +1,196 @@
+package review
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/report"
+	"github.com/olbrichattila/qreview/internal/retriever"
+	"github.com/olbrichattila/qreview/internal/reviewparser"
+)
+
+// fakeModel answers the requests with the answer function, and records them
+type fakeModel struct {
+	mu       sync.Mutex
+	answer   func(request Request, call int) string
+	requests []Request
+}
+
+func (m *fakeModel) Complete(_ context.Context, request Request) (Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, request)
+	return Response{Text: m.answer(request, len(m.requests))}, nil
+}
+
+// fakeRetriever returns the same content for every file
+type fakeRetriever struct {
+	content string
+}
+
+func (r fakeRetriever) Get(_ string) (retriever.Result, error) {
+	return retriever.Result{Kind: retriever.KindFile, FileContent: r.content}, nil
+}
+
+// fakeReporter records the reported entries
+type fakeReporter struct {
+	entries []report.Entry
+}
+
+func (r *fakeReporter) Report(entry report.Entry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *fakeReporter) Summary(_ string) error {
+	return nil
+}
+
+// testFile has blank lines, which are removed before the review, and three top level declarations
+const testFile = "package main\n\nfunc a() {\n}\n\nfunc b() {\n}\n"
+
+func newTestPipeline(model Model, budget TokenBudget, maxChunks int, outputFormat OutputFormat, reporters ...report.Reporter) *Pipeline {
+	return NewPipeline(
+		"test",
+		model,
+		fakeRetriever{content: testFile},
+		"P",
+		reporters,
+		false,
+		budget,
+		maxChunks,
+		outputFormat,
+		Identity{Backend: "fake"},
+	)
+}
+
+func findingLines(findings []reviewparser.Finding) map[int]string {
+	lines := map[int]string{}
+	for _, finding := range findings {
+		lines[finding.StartLine] = finding.Severity
+	}
+
+	return lines
+}
+
+func TestPipelineAnalyzeMapsChunkLinesToTheFile(t *testing.T) {
+	model := &fakeModel{answer: func(request Request, _ int) string {
+		switch {
+		case strings.HasPrefix(request.Content, "func a"):
+			return "Line: 1: [minor] a is empty"
+		case strings.HasPrefix(request.Content, "func b"):
+			return "Line: 2: [major] b is not closed properly"
+		default:
+			return ""
+		}
+	}}
+
+	// Each declaration fits into the budget alone, but not together with the next one
+	pipeline := newTestPipeline(model, TokenBudget{MaxInputTokens: 17, CharsPerToken: 1}, 10, OutputText)
+	analysis, err := pipeline.Analyze("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(model.requests) != 3 {
+		t.Fatalf("got %d requests, want a chunk per declaration", len(model.requests))
+	}
+
+	// The blank lines 2 and 5 are not sent to the model, suggestions cannot replace them
+	for line, want := range map[int]bool{1: true, 2: false, 3: true, 5: false, 7: true, 8: false} {
+		if analysis.Reviewed[line] != want {
+			t.Errorf("got reviewed %t for line %d", analysis.Reviewed[line], line)
+		}
+	}
+
+	// Line 1 of the func a chunk is line 3 of the file, line 2 of the func b chunk is line 7
+	got := findingLines(analysis.Review.Findings)
+	if len(got) != 2 || got[3] != "minor" || got[7] != "major" {
+		t.Errorf("got findings on lines %v, want minor on 3 and major on 7", got)
+	}
+}
+
+func TestPipelineAnalyzeSkipsFilesAboveTheChunkLimit(t *testing.T) {
+	model := &fakeModel{answer: func(Request, int) string { return "" }}
+
+	pipeline := newTestPipeline(model, TokenBudget{MaxInputTokens: 17, CharsPerToken: 1}, 2, OutputText)
+	analysis, err := pipeline.Analyze("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !analysis.Skipped || len(model.requests) != 0 {
+		t.Errorf("got skipped %t after %d requests, want skipped without requests", analysis.Skipped, len(model.requests))
+	}
+
+	if !strings.Contains(analysis.Review.Summary, "Skipped") {
+		t.Errorf("got summary %q", analysis.Review.Summary)
+	}
+}
+
+func TestPipelineAnalyzeRepairsInvalidJSON(t *testing.T) {
+	model := &fakeModel{answer: func(_ Request, call int) string {
+		if call == 1 {
+			return `{"findings": [{"startLine": 2, "severity": "major", "message": "unterminated"`
+		}
+		return `{"summary": "ok", "findings": [{"startLine": 2, "severity": "major", "message": "empty function"}]}`
+	}}
+
+	analysis, err := newTestPipeline(model, TokenBudget{}, 10, OutputJSON).Analyze("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(model.requests) != 2 || !strings.HasPrefix(model.requests[1].Prompt, "Your previous answer was not valid") {
+		t.Fatalf("got %d requests, want the answer sent back to be repaired", len(model.requests))
+	}
+
+	if !analysis.Review.Structured {
+		t.Error("got a text review, want the repaired JSON one")
+	}
+
+	// Line 2 of the line remapped content is line 3 of the file
+	if got := findingLines(analysis.Review.Findings); len(got) != 1 || got[3] != "major" {
+		t.Errorf("got findings on lines %v, want major on 3", got)
+	}
+}
+
+func TestPipelineAnalyzeFallsBackToText(t *testing.T) {
+	model := &fakeModel{answer: func(_ Request, call int) string {
+		if call == 1 {
+			return "Line: 4: [critical] b never returns"
+		}
+		return "still no JSON"
+	}}
+
+	reporter := &fakeReporter{}
+	pipeline := newTestPipeline(model, TokenBudget{}, 10, OutputJSON, reporter)
+	analysis, err := pipeline.Analyze("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(model.requests) != 2 {
+		t.Fatalf("got %d requests, want one repair", len(model.requests))
+	}
+
+	if analysis.Review.Structured {
+		t.Error("got a JSON review, want the first answer parsed as text")
+	}
+
+	// Line 4 of the line remapped content is line 6 of the file
+	if got := findingLines(analysis.Review.Findings); len(got) != 1 || got[6] != "critical" {
+		t.Errorf("got findings on lines %v, want critical on 6", got)
+	}
+
+	if err := pipeline.Publish(analysis); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reporter.entries) != 1 || reporter.entries[0].Definition != "test" || reporter.entries[0].Backend != "fake" {
+		t.Errorf("got report entries %+v", reporter.entries)
+	}
+}
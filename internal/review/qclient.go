@@ -2,81 +2,48 @@ package review
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os/exec"
 	"regexp"
-
-	"github.com/olbrichattila/qreview/internal/helpers"
-	"github.com/olbrichattila/qreview/internal/report"
-	"github.com/olbrichattila/qreview/internal/retriever"
 )
 
-// newAws creates a new AWS q reviewer
-func newAws(
-	retr retriever.Retriever,
-	prompt string,
-	reporters []report.Reporter,
-	commentOnPR bool,
-) Reviewer {
-	return &awsq{
-		reporters:   reporters,
-		retr:        retr,
-		prompt:      prompt,
-		commentOnPR: commentOnPR,
-	}
+// newAws creates a new AWS q model, it runs the q CLI
+func newAws() Model {
+	return &awsq{}
 }
 
-type awsq struct {
-	reporters   []report.Reporter
-	retr        retriever.Retriever
-	prompt      string
-	commentOnPR bool
-}
+type awsq struct{}
 
-// AnalyzeCode returns the result of the analyzed code
-func (a *awsq) AnalyzeCode(fileName string) error {
-	content, err := a.retr.Get(fileName)
-	if err != nil {
-		return fmt.Errorf("Analyze code %w", err)
-	}
+// String returns the backend name
+func (a *awsq) String() string {
+	return clientQ
+}
 
-	remappedContent, lineMap := helpers.SourceCodeLineRemap(content.FileContent)
+// tokenBudget implements budgeted, the prompt is passed as a single argument, which is limited to 128KB on Linux
+func (a *awsq) tokenBudget() TokenBudget {
+	return TokenBudget{MaxInputTokens: 30000, CharsPerToken: 4}
+}
 
+// Complete implements Model.
+func (a *awsq) Complete(ctx context.Context, request Request) (Response, error) {
 	var stdout, stderr bytes.Buffer
 	fmt.Println("executing q command")
-	cmd := exec.Command("/usr/bin/q", "chat", "--no-interactive", a.prompt+remappedContent)
+	cmd := exec.CommandContext(ctx, "/usr/bin/q", "chat", "--no-interactive", request.Message())
 
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err = cmd.Run()
+	err := cmd.Run()
 	if err != nil {
-		fmt.Println("output", out.String())
 		fmt.Printf("stdout: %s\n", stdout.String())
 		fmt.Printf("stderr: %s\n", stderr.String())
-		return fmt.Errorf("cannot execute aws Q command, %w", err)
+		return Response{}, fmt.Errorf("cannot execute aws Q command, %w", err)
 	}
 
 	fmt.Println("executed q command")
-	rawResponse := out.String()
-	aiResponse := stripAnsiCodes(rawResponse)
-
-	// todo do a PR analyzer and comment if line is provided in the response
-	if a.commentOnPR {
-		err = commentOnPRIfNecessary(fileName, aiResponse, content.DiffContent, lineMap)
-		if err != nil {
-			return err
-		}
-	}
-
-	return generateReports(a.reporters, fileName, aiResponse)
-}
 
-// Summary implements Reviewer.
-func (a *awsq) Summary() error {
-	return summary(a.reporters)
+	return Response{Text: stripAnsiCodes(stdout.String())}, nil
 }
 
 // stripAnsiCodes removes ANSI color codes and formatting from the input string
@@ -0,0 +1,107 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const rateLimitWindow = time.Minute
+
+// newRateLimiter creates a sliding window limiter, a zero limit means unlimited
+func newRateLimiter(requestsPerMinute, tokensPerMinute int) *rateLimiter {
+	return &rateLimiter{
+		requestsPerMinute: requestsPerMinute,
+		tokensPerMinute:   tokensPerMinute,
+	}
+}
+
+// rateLimiter keeps the model calls of the last minute, it is shared by all the reviewers,
+// as the quotas belong to the account and not to a definition
+type rateLimiter struct {
+	mu                sync.Mutex
+	requestsPerMinute int
+	tokensPerMinute   int
+	calls             []rateLimitedCall
+}
+
+type rateLimitedCall struct {
+	at     time.Time
+	tokens int
+}
+
+// wait blocks until a call with the given number of tokens fits into the limits, then records it
+func (l *rateLimiter) wait(ctx context.Context, tokens int) error {
+	for {
+		wait := l.reserve(tokens)
+		if wait == 0 {
+			return nil
+		}
+
+		fmt.Printf("AI rate limit reached, waiting %s\n", wait.Round(time.Millisecond))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve records the call and returns 0 if it fits, otherwise returns how long to wait before trying again
+func (l *rateLimiter) reserve(tokens int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	calls := l.calls[:0]
+	usedTokens := 0
+	for _, call := range l.calls {
+		if now.Sub(call.at) < rateLimitWindow {
+			calls = append(calls, call)
+			usedTokens += call.tokens
+		}
+	}
+	l.calls = calls
+
+	// A single call above the token limit is let through on an empty window, otherwise it would wait forever
+	requestsExceeded := l.requestsPerMinute > 0 && len(l.calls)+1 > l.requestsPerMinute
+	tokensExceeded := l.tokensPerMinute > 0 && len(l.calls) > 0 && usedTokens+tokens > l.tokensPerMinute
+	if requestsExceeded || tokensExceeded {
+		return l.calls[0].at.Add(rateLimitWindow).Sub(now) + time.Millisecond
+	}
+
+	l.calls = append(l.calls, rateLimitedCall{at: now, tokens: tokens})
+	return 0
+}
+
+// withRateLimit wraps the model, calls wait until they fit into the limits
+func withRateLimit(model Model, limiter *rateLimiter) Model {
+	if limiter == nil || (limiter.requestsPerMinute <= 0 && limiter.tokensPerMinute <= 0) {
+		return model
+	}
+
+	return &rateLimitedModel{
+		model:   model,
+		limiter: limiter,
+	}
+}
+
+type rateLimitedModel struct {
+	model   Model
+	limiter *rateLimiter
+}
+
+// Complete implements Model.
+func (r *rateLimitedModel) Complete(ctx context.Context, request Request) (Response, error) {
+	if err := r.limiter.wait(ctx, estimateTokens(request.Message())); err != nil {
+		return Response{}, err
+	}
+
+	return r.model.Complete(ctx, request)
+}
+
+// estimateTokens is a rough input token estimate, around four characters per token for code and English text
+func estimateTokens(text string) int {
+	return len(text)/4 + 1
+}
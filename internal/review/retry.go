@@ -0,0 +1,145 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// RetryPolicy tells how many times and how long to wait between failed model calls
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// delay returns the exponential backoff for the given (1 based) attempt, with jitter between half and full delay
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// statusError is returned by HTTP based models when the API does not answer with 200
+type statusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *statusError) Error() string {
+	return e.Message
+}
+
+// newStatusError creates a statusError from the response, reading the Retry-After header if any
+func newStatusError(resp *http.Response, message string) *statusError {
+	statusErr := &statusError{
+		StatusCode: resp.StatusCode,
+		Message:    message,
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		statusErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return statusErr
+}
+
+// withRetry wraps the model, failed calls are repeated if the error is retryable
+func withRetry(model Model, policy RetryPolicy) Model {
+	if policy.MaxAttempts <= 1 {
+		return model
+	}
+
+	return &retryModel{
+		model:  model,
+		policy: policy,
+	}
+}
+
+type retryModel struct {
+	model  Model
+	policy RetryPolicy
+}
+
+// Complete implements Model.
+func (r *retryModel) Complete(ctx context.Context, request Request) (Response, error) {
+	var err error
+	for attempt := 1; attempt <= r.policy.MaxAttempts; attempt++ {
+		var response Response
+		response, err = r.model.Complete(ctx, request)
+		if err == nil {
+			return response, nil
+		}
+
+		if !isRetryable(err) || attempt == r.policy.MaxAttempts {
+			break
+		}
+
+		wait := r.policy.delay(attempt)
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > wait {
+			wait = statusErr.RetryAfter
+		}
+
+		fmt.Printf("AI call failed (attempt %d/%d): %s, retrying in %s\n", attempt, r.policy.MaxAttempts, err, wait.Round(time.Millisecond))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return Response{}, ctx.Err()
+		}
+	}
+
+	return Response{}, err
+}
+
+// isRetryable classifies the errors of the backends, throttling, timeouts, connection resets and server side errors are retried.
+// Anything else, like a refused connection, a bad request or a failed Q CLI, fails the same way again
+func isRetryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests ||
+			statusErr.StatusCode == http.StatusRequestTimeout ||
+			statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var throttling *types.ThrottlingException
+	var unavailable *types.ServiceUnavailableException
+	var modelTimeout *types.ModelTimeoutException
+	var modelNotReady *types.ModelNotReadyException
+	var internal *types.InternalServerException
+	if errors.As(err, &throttling) ||
+		errors.As(err, &unavailable) ||
+		errors.As(err, &modelTimeout) ||
+		errors.As(err, &modelNotReady) ||
+		errors.As(err, &internal) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
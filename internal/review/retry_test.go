@@ -0,0 +1,144 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// timeoutError is a net.Error, timed out or not
+type timeoutError struct {
+	timeout bool
+}
+
+func (e timeoutError) Error() string   { return "i/o" }
+func (e timeoutError) Timeout() bool   { return e.timeout }
+func (e timeoutError) Temporary() bool { return false }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), true},
+		{"network timeout", &net.OpError{Op: "read", Err: timeoutError{timeout: true}}, true},
+		{"connection reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, true},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, false},
+		{"other network error", timeoutError{timeout: false}, false},
+		{"too many requests", &statusError{StatusCode: 429}, true},
+		{"request timeout", &statusError{StatusCode: 408}, true},
+		{"server error", &statusError{StatusCode: 502}, true},
+		{"bad request", &statusError{StatusCode: 400}, false},
+		{"unauthorized", &statusError{StatusCode: 401}, false},
+		{"bedrock throttling", &types.ThrottlingException{}, true},
+		{"bedrock validation", &types.ValidationException{}, false},
+		{"q cli exit", fmt.Errorf("cannot execute aws Q command, %w", &exec.ExitError{}), false},
+		{"canceled", context.Canceled, false},
+		{"other", errors.New("no answer"), false},
+	}
+
+	for _, test := range tests {
+		if got := isRetryable(test.err); got != test.want {
+			t.Errorf("%s: got retryable %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelayIsCapped(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	// The jitter keeps the delay between the half and the full backoff
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 9: 5 * time.Second} {
+		for range 20 {
+			if got := policy.delay(attempt); got < want/2 || got > want {
+				t.Errorf("got delay %s of attempt %d, want between %s and %s", got, attempt, want/2, want)
+			}
+		}
+	}
+
+	if got := (RetryPolicy{MaxAttempts: 3}).delay(2); got != 0 {
+		t.Errorf("got delay %s without a base delay", got)
+	}
+}
+
+// failingModel fails with the error the given number of times, then answers
+type failingModel struct {
+	err      error
+	failures int
+	calls    int
+}
+
+func (m *failingModel) Complete(context.Context, Request) (Response, error) {
+	m.calls++
+	if m.calls <= m.failures {
+		return Response{}, m.err
+	}
+
+	return Response{Text: "ok"}, nil
+}
+
+func TestRetryModelRetriesOnlyRetryableErrors(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	throttled := &failingModel{err: &statusError{StatusCode: 429}, failures: 2}
+	if response, err := withRetry(throttled, policy).Complete(context.Background(), Request{}); err != nil || response.Text != "ok" || throttled.calls != 3 {
+		t.Errorf("got %q, %v after %d calls, want the answer of the third call", response.Text, err, throttled.calls)
+	}
+
+	rejected := &failingModel{err: &statusError{StatusCode: 400}, failures: 2}
+	if _, err := withRetry(rejected, policy).Complete(context.Background(), Request{}); err == nil || rejected.calls != 1 {
+		t.Errorf("got %v after %d calls, want the error of the first call", err, rejected.calls)
+	}
+
+	down := &failingModel{err: &statusError{StatusCode: 503}, failures: 5}
+	if _, err := withRetry(down, policy).Complete(context.Background(), Request{}); err == nil || down.calls != 3 {
+		t.Errorf("got %v after %d calls, want the error after the last attempt", err, down.calls)
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	requests := newRateLimiter(2, 0)
+	if requests.reserve(10) != 0 || requests.reserve(10) != 0 {
+		t.Fatal("got a wait within the request limit")
+	}
+
+	if wait := requests.reserve(10); wait <= 0 || wait > rateLimitWindow+time.Millisecond {
+		t.Errorf("got wait %s above the request limit, want until the first call leaves the window", wait)
+	}
+
+	tokens := newRateLimiter(0, 100)
+	if tokens.reserve(500) != 0 {
+		t.Error("got a wait for a call above the token limit on an empty window, it would wait forever")
+	}
+
+	if tokens.reserve(1) == 0 {
+		t.Error("got no wait above the token limit")
+	}
+
+	// Calls older than the window do not count
+	tokens.calls[0].at = time.Now().Add(-rateLimitWindow)
+	if tokens.reserve(50) != 0 {
+		t.Error("got a wait after the window passed")
+	}
+}
+
+func TestRateLimiterWaitStopsOnCancel(t *testing.T) {
+	limiter := newRateLimiter(1, 0)
+	if err := limiter.wait(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.wait(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want canceled", err)
+	}
+}
@@ -0,0 +1,149 @@
+package reviewparser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Finding is a single issue found by the review, line numbers are 1 based and inclusive
+type Finding struct {
+	File       string `json:"file"`
+	StartLine  int    `json:"startLine"`
+	EndLine    int    `json:"endLine"`
+	Severity   string `json:"severity"`
+	Category   string `json:"category"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+	// Replacement is the code replacing the lines from StartLine to EndLine, empty if there is no concrete fix
+	Replacement string `json:"replacement"`
+}
+
+// Markdown renders the finding for reports and PR comments
+func (f Finding) Markdown() string {
+	var md strings.Builder
+
+	var tags []string
+	if f.Severity != "" {
+		tags = append(tags, "**"+f.Severity+"**")
+	}
+	if f.Category != "" {
+		tags = append(tags, f.Category)
+	}
+	if len(tags) > 0 {
+		md.WriteString(strings.Join(tags, " · ") + ": ")
+	}
+
+	md.WriteString(f.Message)
+	if f.Suggestion != "" {
+		md.WriteString("\n\n**Suggestion:** " + f.Suggestion)
+	}
+	if f.Replacement != "" {
+		md.WriteString("\n\n" + CodeBlock("", f.Replacement))
+	}
+
+	return md.String()
+}
+
+// CodeBlock fences the code for markdown, the fence is longer than any backtick run in the code
+func CodeBlock(info, code string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	return fence + info + "\n" + strings.TrimSuffix(code, "\n") + "\n" + fence
+}
+
+// Markdown returns the review for reports, the model answer for free text reviews, rendered findings for structured ones
+func (r Response) Markdown() string {
+	if !r.Structured {
+		return r.Raw
+	}
+
+	var md strings.Builder
+	if r.Summary != "" {
+		md.WriteString(r.Summary + "\n\n")
+	}
+
+	if len(r.Findings) == 0 {
+		md.WriteString("No issues found.\n")
+	}
+
+	for _, finding := range r.Findings {
+		if finding.EndLine > finding.StartLine {
+			md.WriteString(fmt.Sprintf("### Line %d-%d\n\n", finding.StartLine, finding.EndLine))
+		} else {
+			md.WriteString(fmt.Sprintf("### Line %d\n\n", finding.StartLine))
+		}
+		md.WriteString(finding.Markdown() + "\n\n")
+	}
+
+	return md.String()
+}
+
+// Shift adds the offset to every line number, used when the review was made on a chunk starting after offset lines
+func (r Response) Shift(offset int) Response {
+	if offset == 0 {
+		return r
+	}
+
+	return r.MapLines(func(lineNr int) int {
+		return lineNr + offset
+	})
+}
+
+// MapLines returns the response with every line number converted by mapLine
+func (r Response) MapLines(mapLine func(int) int) Response {
+	mapped := r
+	if !r.Structured {
+		// the raw answer is what the reports show, keep it in line with the findings
+		mapped.Raw = mapLineReferences(r.Raw, mapLine)
+	}
+
+	mapped.Lines = make(map[LineRange]string, len(r.Lines))
+	for lineRange, comment := range r.Lines {
+		mapped.Lines[LineRange{Start: mapLine(lineRange.Start), End: mapLine(lineRange.End)}] += comment
+	}
+
+	mapped.Findings = make([]Finding, len(r.Findings))
+	for i, finding := range r.Findings {
+		finding.StartLine = mapLine(finding.StartLine)
+		finding.EndLine = mapLine(finding.EndLine)
+		mapped.Findings[i] = finding
+	}
+
+	return mapped
+}
+
+// Merge joins the reviews of the chunks of a file into one
+func Merge(responses []Response) Response {
+	if len(responses) == 1 {
+		return responses[0]
+	}
+
+	merged := Response{
+		Lines:      map[LineRange]string{},
+		Structured: len(responses) > 0,
+	}
+
+	var raws, summaries []string
+	for _, response := range responses {
+		raws = append(raws, response.Markdown())
+		if response.Summary != "" && !slices.Contains(summaries, response.Summary) {
+			summaries = append(summaries, response.Summary)
+		}
+
+		for lineRange, comment := range response.Lines {
+			merged.Lines[lineRange] += comment
+		}
+
+		merged.Findings = append(merged.Findings, response.Findings...)
+		merged.Structured = merged.Structured && response.Structured
+	}
+
+	merged.Raw = strings.Join(raws, "\n\n")
+	merged.Summary = strings.Join(summaries, "\n\n")
+
+	return merged
+}
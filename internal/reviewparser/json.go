@@ -0,0 +1,136 @@
+package reviewparser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// JSONInstructions is appended to the prompt of structured definitions, it describes the expected answer
+const JSONInstructions = `Answer ONLY with a JSON object, without any text or markdown around it, matching this schema:
+{
+  "summary": "string, short overall assessment",
+  "findings": [
+    {
+      "file": "string, the reviewed file name",
+      "startLine": "integer >= 1, first line of the issue, counted from the first line of the code",
+      "endLine": "integer >= startLine, last line of the issue",
+      "severity": "one of: info, minor, major, critical",
+      "category": "string, like bug, security, performance, style, maintainability",
+      "message": "string, the issue explained",
+      "suggestion": "string, how to fix it, may be empty",
+      "replacement": "string, the exact code replacing the lines from startLine to endLine with the original indentation, empty if there is no concrete fix"
+    }
+  ]
+}
+Return an empty findings array if there are no issues.`
+
+// structuredReview is the JSON answer of the model
+type structuredReview struct {
+	Summary  string    `json:"summary"`
+	Findings []Finding `json:"findings"`
+}
+
+// ParseJSON parses and validates a structured review, the JSON may be wrapped in a markdown code fence or text
+func ParseJSON(answer, fileName string) (Response, error) {
+	jsonText, err := extractJSON(answer)
+	if err != nil {
+		return Response{}, err
+	}
+
+	var review structuredReview
+	if err := json.Unmarshal([]byte(jsonText), &review); err != nil {
+		// Some models answer with the findings array only
+		var findings []Finding
+		if arrErr := json.Unmarshal([]byte(jsonText), &findings); arrErr != nil {
+			return Response{}, fmt.Errorf("invalid JSON review: %w", err)
+		}
+		review = structuredReview{Findings: findings}
+	}
+
+	if err := validate(&review, fileName); err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Summary:    review.Summary,
+		Lines:      linesFromFindings(review.Findings),
+		Findings:   review.Findings,
+		Raw:        answer,
+		Structured: true,
+	}, nil
+}
+
+// RepairPrompt asks the model to fix its malformed JSON answer, the answer is sent as the content
+func RepairPrompt(err error) string {
+	return fmt.Sprintf(
+		"Your previous answer was not valid: %s\nFix it and %s\nThe previous answer:\n",
+		err,
+		strings.ToLower(JSONInstructions[:1])+JSONInstructions[1:],
+	)
+}
+
+// extractJSON returns the outermost JSON object or array of the answer
+func extractJSON(answer string) (string, error) {
+	start := strings.IndexAny(answer, "{[")
+	if start == -1 {
+		return "", errors.New("the answer does not contain JSON")
+	}
+
+	closing := "}"
+	if answer[start] == '[' {
+		closing = "]"
+	}
+
+	end := strings.LastIndex(answer, closing)
+	if end < start {
+		return "", errors.New("the JSON in the answer is not closed")
+	}
+
+	return answer[start : end+1], nil
+}
+
+// validate checks the findings against the schema, and fills in the optional fields
+func validate(review *structuredReview, fileName string) error {
+	var errs []error
+	for i := range review.Findings {
+		finding := &review.Findings[i]
+		if finding.File == "" {
+			finding.File = fileName
+		}
+
+		if strings.TrimSpace(finding.Message) == "" {
+			errs = append(errs, fmt.Errorf("findings[%d].message is required", i))
+		}
+
+		if finding.StartLine < 1 {
+			errs = append(errs, fmt.Errorf("findings[%d].startLine should be at least 1, got %d", i, finding.StartLine))
+		}
+
+		if finding.EndLine == 0 {
+			finding.EndLine = finding.StartLine
+		}
+
+		if finding.EndLine < finding.StartLine {
+			errs = append(errs, fmt.Errorf("findings[%d].endLine %d is before startLine %d", i, finding.EndLine, finding.StartLine))
+		}
+
+		finding.Severity = strings.ToLower(strings.TrimSpace(finding.Severity))
+		if finding.Severity != "" && SeverityRank(finding.Severity) == 0 {
+			errs = append(errs, fmt.Errorf("findings[%d].severity should be one of %s, got %s", i, strings.Join(Severities, ", "), finding.Severity))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// linesFromFindings keeps Lines in line with the findings, for consumers reading the comments by line
+func linesFromFindings(findings []Finding) map[LineRange]string {
+	lines := map[LineRange]string{}
+	for _, finding := range findings {
+		lines[LineRange{Start: finding.StartLine, End: finding.EndLine}] += finding.Markdown() + "\n"
+	}
+
+	return lines
+}
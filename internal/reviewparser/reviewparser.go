@@ -3,22 +3,41 @@ package reviewparser
 import (
 	"bufio"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 // Response is the entire review spited, lines are separated, rest as summary
 type Response struct {
-	Summary string
-	Lines   map[int]string
+	Summary    string
+	Lines      map[LineRange]string
+	Findings   []Finding
+	Raw        string // The answer of the model as it is
+	Structured bool   // The findings were parsed from JSON
 }
 
+// LineRange is the lines a comment is about, 1 based and inclusive, Start and End are equal for a single line
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// singleLine returns the range of a single line
+func singleLine(lineNr int) LineRange {
+	return LineRange{Start: lineNr, End: lineNr}
+}
+
+// Parse parses the free text review, the comments of each line or line range become a finding.
+// Comments starting with a severity tag, like Line: 3: [major] ..., are classified
 func Parse(mdFile string) Response {
-	currentLineNr := 1
+	currentRange := singleLine(1)
+	severities := map[LineRange]string{}
 
 	response := Response{
 		Summary: "This is an automated review",
-		Lines:   map[int]string{},
+		Lines:   map[LineRange]string{},
+		Raw:     mdFile,
 	}
 
 	scanner := bufio.NewScanner(strings.NewReader(mdFile))
@@ -29,60 +48,108 @@ func Parse(mdFile string) Response {
 			continue
 		}
 
-		if lineNr, comment, ok := hasLineNumber(line); ok {
-			currentLineNr = lineNr
-			response.Lines[lineNr] += comment + "\n"
+		if lineRange, comment, ok := hasLineNumber(line); ok {
+			currentRange = lineRange
+			severity, comment := severityTag(comment)
+			if SeverityRank(severity) > SeverityRank(severities[lineRange]) {
+				severities[lineRange] = severity
+			}
+			response.Lines[lineRange] += comment + "\n"
 			continue
 		}
 
-		response.Lines[currentLineNr] += line + "\n"
+		response.Lines[currentRange] += line + "\n"
 	}
 
+	response.Findings = findingsFromLines(response.Lines, severities)
+
 	return response
 }
 
-func hasLineNumber(str string) (int, string, bool) {
-	if lineNr, result, ok := hasLineNumberByRange(str); ok {
-		return lineNr, result, ok
+// findingsFromLines converts the line comments of a free text review to findings, ordered by line
+func findingsFromLines(lines map[LineRange]string, severities map[LineRange]string) []Finding {
+	lineRanges := make([]LineRange, 0, len(lines))
+	for lineRange := range lines {
+		lineRanges = append(lineRanges, lineRange)
+	}
+	sort.Slice(lineRanges, func(i, j int) bool {
+		if lineRanges[i].Start != lineRanges[j].Start {
+			return lineRanges[i].Start < lineRanges[j].Start
+		}
+		return lineRanges[i].End < lineRanges[j].End
+	})
+
+	findings := make([]Finding, 0, len(lineRanges))
+	for _, lineRange := range lineRanges {
+		findings = append(findings, Finding{
+			StartLine: lineRange.Start,
+			EndLine:   lineRange.End,
+			Severity:  severities[lineRange],
+			Message:   strings.TrimSpace(lines[lineRange]),
+		})
+	}
+
+	return findings
+}
+
+func hasLineNumber(str string) (LineRange, string, bool) {
+	if lineRange, result, ok := hasLineNumberByRange(str); ok {
+		return lineRange, result, ok
 	}
 
 	return hasLineNumberBySingle(str)
 }
 
-func hasLineNumberByRange(str string) (int, string, bool) {
+func hasLineNumberByRange(str string) (LineRange, string, bool) {
 	return hasLineNumberByRegex(str, `(?i)Line:?\s*\d+-\d+[:*]`)
 }
 
-func hasLineNumberBySingle(str string) (int, string, bool) {
+func hasLineNumberBySingle(str string) (LineRange, string, bool) {
 	return hasLineNumberByRegex(str, `(?i)Line:?\s*\d+[:*]`)
 }
 
-func hasLineNumberByRegex(str, regex string) (int, string, bool) {
+func hasLineNumberByRegex(str, regex string) (LineRange, string, bool) {
 	rangeRegex := regexp.MustCompile(regex)
 	rangeMatch := rangeRegex.FindStringIndex(str)
 	numberRegex := regexp.MustCompile(`\d+`)
 
-	var matchText string
-	var endPos int
-
-	if rangeMatch != nil {
-		matchText = str[rangeMatch[0]:rangeMatch[1]]
-		endPos = rangeMatch[1]
-		lineNr := 1
-		numMatch := numberRegex.FindString(matchText)
-		if numMatch != "" {
-			if firstNumber, err := strconv.Atoi(numMatch); err == nil {
-				lineNr = firstNumber
-			}
-		}
+	if rangeMatch == nil {
+		return LineRange{}, "", false
+	}
 
-		// PR is 1 indexed, make sure no 0 returned
-		if lineNr == 0 {
-			lineNr = 1
+	matchText := str[rangeMatch[0]:rangeMatch[1]]
+	var numbers []int
+	for _, numMatch := range numberRegex.FindAllString(matchText, 2) {
+		if number, err := strconv.Atoi(numMatch); err == nil {
+			// PR is 1 indexed, make sure no 0 returned
+			numbers = append(numbers, max(number, 1))
 		}
+	}
 
-		return lineNr, str[endPos:], true
+	lineRange := singleLine(1)
+	if len(numbers) > 0 {
+		lineRange = singleLine(numbers[0])
 	}
 
-	return 0, "", false
+	if len(numbers) > 1 && numbers[1] > lineRange.Start {
+		lineRange.End = numbers[1]
+	}
+
+	return lineRange, str[rangeMatch[1]:], true
+}
+
+// mapLineReferences converts the line numbers of every line reference of the review by mapLine
+func mapLineReferences(mdFile string, mapLine func(int) int) string {
+	lineRefRegex := regexp.MustCompile(`(?i)(Line:?\s*)(\d+)(?:-(\d+))?([:*])`)
+	return lineRefRegex.ReplaceAllStringFunc(mdFile, func(match string) string {
+		parts := lineRefRegex.FindStringSubmatch(match)
+		start, _ := strconv.Atoi(parts[2])
+		mapped := parts[1] + strconv.Itoa(mapLine(start))
+		if parts[3] != "" {
+			end, _ := strconv.Atoi(parts[3])
+			mapped += "-" + strconv.Itoa(mapLine(end))
+		}
+
+		return mapped + parts[4]
+	})
 }
@@ -0,0 +1,42 @@
+package reviewparser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Severities, from the least to the most important
+var Severities = []string{"info", "minor", "major", "critical"}
+
+// severityTagRegex matches the severity at the beginning of a free text comment, like [major], **major** or major:
+var severityTagRegex = regexp.MustCompile(`(?i)^\s*([\[(*]*)\s*(info|minor|major|critical)\s*([\])*:]*)\s*:?\s*`)
+
+// SeverityRank returns the position of the severity in Severities starting from 1, 0 if it is not a known severity
+func SeverityRank(severity string) int {
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	for i, s := range Severities {
+		if s == severity {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// AtLeast tells if the finding is classified, and its severity is the threshold or above
+func (f Finding) AtLeast(threshold string) bool {
+	rank := SeverityRank(f.Severity)
+	return rank > 0 && rank >= SeverityRank(threshold)
+}
+
+// severityTag splits the severity tag from the beginning of a free text comment,
+// the severity is empty if the comment is not tagged
+func severityTag(comment string) (string, string) {
+	match := severityTagRegex.FindStringSubmatch(comment)
+	// A bare word like "Major refactor" is not a tag, it has to be enclosed or followed by a colon
+	if match == nil || (match[1] == "" && match[3] == "") {
+		return "", comment
+	}
+
+	return strings.ToLower(match[2]), comment[len(match[0]):]
+}
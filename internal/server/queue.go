@@ -0,0 +1,229 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/pr"
+)
+
+// queueHistory is the number of finished jobs kept for the queue status
+const queueHistory = 50
+
+// Mode is what a job does with the pull request
+type Mode string
+
+const (
+	ModeReview Mode = "review" // Review the pull request and comment on it
+	ModeReply  Mode = "reply"  // Answer the review threads mentioning qreview
+)
+
+// Job states
+const (
+	StateQueued  = "queued"
+	StateRunning = "running"
+	StateDone    = "done"
+	StateFailed  = "failed"
+)
+
+var errQueueFull = errors.New("the job queue is full")
+
+// Job is a review or a reply asked for by a webhook event
+type Job struct {
+	ID       int64      `json:"id"`
+	Kind     pr.Kind    `json:"forge"`
+	PRURL    string     `json:"prUrl"`
+	Mode     Mode       `json:"mode"`
+	Event    string     `json:"event"` // The webhook event which queued the job
+	State    string     `json:"state"`
+	Queued   time.Time  `json:"queued"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// key identifies the work of the job, a job is not queued again while the same one is waiting
+func (j Job) key() string {
+	return string(j.Mode) + "\x00" + j.PRURL
+}
+
+// Status is the state of the queue, with the waiting, running and last finished jobs
+type Status struct {
+	Queued      int   `json:"queued"`
+	Running     int   `json:"running"`
+	Concurrency int   `json:"concurrency"`
+	Capacity    int   `json:"capacity"`
+	Done        int64 `json:"done"`
+	Failed      int64 `json:"failed"`
+	Jobs        []Job `json:"jobs"`
+}
+
+// queue runs the jobs with the runner on a bounded number of workers, in the order they were pushed
+type queue struct {
+	runner      Runner
+	concurrency int
+	jobs        chan *Job
+	wg          sync.WaitGroup
+
+	mu       sync.Mutex
+	nextID   int64
+	closed   bool
+	waiting  map[string]*Job // Queued jobs by key
+	running  map[int64]*Job
+	busy     map[string]chan struct{} // Closed when the running job of the key finishes
+	finished []*Job                   // The last finished jobs, the oldest first
+	done     int64
+	failed   int64
+}
+
+func newQueue(runner Runner, concurrency, size int) *queue {
+	return &queue{
+		runner:      runner,
+		concurrency: concurrency,
+		jobs:        make(chan *Job, size),
+		waiting:     map[string]*Job{},
+		running:     map[int64]*Job{},
+		busy:        map[string]chan struct{}{},
+	}
+}
+
+// start starts the workers
+func (q *queue) start() {
+	for i := 0; i < q.concurrency; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for job := range q.jobs {
+				q.run(job)
+			}
+		}()
+	}
+}
+
+// push queues the job, false if the same job is already waiting, which runs on the latest commit of the PR anyway
+func (q *queue) push(job Job) (Job, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return Job{}, false, errors.New("the server is shutting down")
+	}
+
+	if waiting, ok := q.waiting[job.key()]; ok {
+		return *waiting, false, nil
+	}
+
+	q.nextID++
+	job.ID = q.nextID
+	job.State = StateQueued
+	job.Queued = time.Now()
+
+	queued := &job
+	select {
+	case q.jobs <- queued:
+	default:
+		q.nextID--
+		return Job{}, false, errQueueFull
+	}
+
+	q.waiting[job.key()] = queued
+	return job, true, nil
+}
+
+// run runs the job and records its result
+func (q *queue) run(job *Job) {
+	q.mu.Lock()
+	// The same work on the same PR runs one at a time, so the comments of the first run are seen by the next one
+	for busy, ok := q.busy[job.key()]; ok && !q.closed; busy, ok = q.busy[job.key()] {
+		q.mu.Unlock()
+		<-busy
+		q.mu.Lock()
+	}
+
+	if q.closed {
+		delete(q.waiting, job.key())
+		q.mu.Unlock()
+		return
+	}
+
+	busy := make(chan struct{})
+	q.busy[job.key()] = busy
+	defer close(busy)
+
+	started := time.Now()
+	delete(q.waiting, job.key())
+	job.State = StateRunning
+	job.Started = &started
+	q.running[job.ID] = job
+	snapshot := *job
+	q.mu.Unlock()
+
+	fmt.Printf("Job %d started: %s of %s (%s)\n", snapshot.ID, snapshot.Mode, snapshot.PRURL, snapshot.Event)
+	err := q.runner.Run(snapshot)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	finished := time.Now()
+	job.Finished = &finished
+	if err != nil {
+		job.State = StateFailed
+		job.Error = err.Error()
+		q.failed++
+	} else {
+		job.State = StateDone
+		q.done++
+	}
+
+	delete(q.running, job.ID)
+	delete(q.busy, job.key())
+	q.finished = append(q.finished, job)
+	if len(q.finished) > queueHistory {
+		q.finished = q.finished[len(q.finished)-queueHistory:]
+	}
+
+	fmt.Printf("Job %d %s in %s\n", job.ID, job.State, finished.Sub(started).Round(time.Second))
+}
+
+// status returns a copy of the state of the queue, the newest jobs first
+func (q *queue) status() Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := Status{
+		Queued:      len(q.waiting),
+		Running:     len(q.running),
+		Concurrency: q.concurrency,
+		Capacity:    cap(q.jobs),
+		Done:        q.done,
+		Failed:      q.failed,
+		Jobs:        []Job{},
+	}
+
+	for _, job := range q.waiting {
+		status.Jobs = append(status.Jobs, *job)
+	}
+	for _, job := range q.running {
+		status.Jobs = append(status.Jobs, *job)
+	}
+	sort.Slice(status.Jobs, func(i, j int) bool { return status.Jobs[i].ID > status.Jobs[j].ID })
+
+	for i := len(q.finished) - 1; i >= 0; i-- {
+		status.Jobs = append(status.Jobs, *q.finished[i])
+	}
+
+	return status
+}
+
+// stop stops accepting jobs, drops the waiting ones, and waits for the running ones to finish
+func (q *queue) stop() {
+	q.mu.Lock()
+	q.closed = true
+	close(q.jobs)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
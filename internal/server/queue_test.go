@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/pr"
+)
+
+// fakeRunner records the jobs it runs, and signals on ran if it is set
+type fakeRunner struct {
+	mu   sync.Mutex
+	jobs []Job
+	ran  chan struct{}
+}
+
+func (r *fakeRunner) Run(job Job) error {
+	r.mu.Lock()
+	r.jobs = append(r.jobs, job)
+	r.mu.Unlock()
+
+	if r.ran != nil {
+		r.ran <- struct{}{}
+	}
+
+	return nil
+}
+
+func TestQueuePushDropsDuplicates(t *testing.T) {
+	q := newQueue(&fakeRunner{}, 1, 10)
+	review := Job{Kind: pr.KindGitHub, Mode: ModeReview, PRURL: "https://github.com/o/r/pull/1"}
+
+	first, isNew, err := q.push(review)
+	if err != nil || !isNew {
+		t.Fatalf("got %t, %v, want a new job", isNew, err)
+	}
+
+	again, isNew, err := q.push(review)
+	if err != nil || isNew || again.ID != first.ID {
+		t.Errorf("got job %d, new %t, %v, want the waiting job %d", again.ID, isNew, err, first.ID)
+	}
+
+	// A reply is other work on the same PR
+	reply := review
+	reply.Mode = ModeReply
+	if _, isNew, err := q.push(reply); err != nil || !isNew {
+		t.Errorf("got %t, %v for a reply, want a new job", isNew, err)
+	}
+
+	if status := q.status(); status.Queued != 2 {
+		t.Errorf("got %d queued jobs, want 2", status.Queued)
+	}
+}
+
+func TestQueuePushReportsFullQueue(t *testing.T) {
+	q := newQueue(&fakeRunner{}, 1, 2)
+	for _, prURL := range []string{"https://github.com/o/r/pull/1", "https://github.com/o/r/pull/2"} {
+		if _, _, err := q.push(Job{Mode: ModeReview, PRURL: prURL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, _, err := q.push(Job{Mode: ModeReview, PRURL: "https://github.com/o/r/pull/3"})
+	if !errors.Is(err, errQueueFull) {
+		t.Fatalf("got error %v, want the queue full", err)
+	}
+
+	// A duplicate of a waiting job is still accepted
+	if _, isNew, err := q.push(Job{Mode: ModeReview, PRURL: "https://github.com/o/r/pull/1"}); err != nil || isNew {
+		t.Errorf("got %t, %v, want the waiting job", isNew, err)
+	}
+}
+
+func TestQueueRunsTheJobs(t *testing.T) {
+	runner := &fakeRunner{ran: make(chan struct{}, 2)}
+	q := newQueue(runner, 2, 10)
+	for _, prURL := range []string{"https://github.com/o/r/pull/1", "https://github.com/o/r/pull/2"} {
+		if _, _, err := q.push(Job{Mode: ModeReview, PRURL: prURL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q.start()
+	<-runner.ran
+	<-runner.ran
+	q.stop()
+
+	if status := q.status(); status.Done != 2 || status.Queued != 0 || status.Running != 0 {
+		t.Errorf("got status %+v", status)
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	cmdinterpreter "github.com/olbrichattila/qreview/internal/cmd-interpreter"
+	"github.com/olbrichattila/qreview/internal/pr"
+)
+
+// Runner runs a job to the end, the error tells that the job failed
+type Runner interface {
+	Run(job Job) error
+}
+
+// NewExecRunner returns a runner starting the qreview executable for each job,
+// so every job starts with a clean state, like a run from the command line
+func NewExecRunner() (Runner, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the qreview executable: %w", err)
+	}
+
+	return &execRunner{executable: executable, output: os.Stdout}, nil
+}
+
+type execRunner struct {
+	executable string
+	output     io.Writer
+}
+
+// Run implements Runner, the output of the job is printed with the job ID in front of each line
+func (r *execRunner) Run(job Job) error {
+	cmd := exec.Command(r.executable, args(job, time.Now())...)
+	out := &prefixWriter{w: r.output, prefix: fmt.Sprintf("[job %d] ", job.ID)}
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	err := cmd.Run()
+	out.flush()
+
+	return err
+}
+
+// args returns the command line arguments running the job. Every job has its own report folder,
+// jobs finishing in the same minute would overwrite the reports of each other otherwise
+func args(job Job, started time.Time) []string {
+	flag := cmdinterpreter.FlagPR
+	switch job.Kind {
+	case pr.KindGitHub:
+		flag = cmdinterpreter.FlagGithubPR
+	case pr.KindGitLab:
+		flag = cmdinterpreter.FlagGitlabMR
+	}
+
+	reportDir := fmt.Sprintf("report/%s-job-%d", started.Format("2006/01/02/15_04_05"), job.ID)
+	args := []string{"-" + flag + "=" + job.PRURL, "-" + cmdinterpreter.FlagComment, "-" + cmdinterpreter.FlagReportDir + "=" + reportDir}
+	if job.Mode == ModeReply {
+		args = append(args, "-"+cmdinterpreter.FlagReply)
+	}
+
+	return args
+}
+
+// prefixWriter writes whole lines with the prefix, so the output of parallel jobs does not mix within a line
+type prefixWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i == -1 {
+			break
+		}
+
+		if _, err := p.w.Write(append([]byte(p.prefix), p.buf[:i+1]...)); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+
+	return len(data), nil
+}
+
+// flush writes the last line, if it did not end with a new line
+func (p *prefixWriter) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buf) > 0 {
+		p.w.Write(append([]byte(p.prefix), append(p.buf, '\n')...))
+		p.buf = nil
+	}
+}
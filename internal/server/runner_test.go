@@ -0,0 +1,25 @@
+package server
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/pr"
+)
+
+func TestArgsGiveEveryJobItsReportFolder(t *testing.T) {
+	started := time.Date(2026, 10, 18, 9, 30, 15, 0, time.UTC)
+	first := args(Job{ID: 7, Kind: pr.KindGitHub, Mode: ModeReview, PRURL: "https://github.com/o/r/pull/1"}, started)
+	second := args(Job{ID: 8, Kind: pr.KindGitLab, Mode: ModeReply, PRURL: "https://gitlab.com/g/p/-/merge_requests/2"}, started)
+
+	want := []string{"-githubpr=https://github.com/o/r/pull/1", "-comment", "-report-dir=report/2026/10/18/09_30_15-job-7"}
+	if !slices.Equal(first, want) {
+		t.Errorf("got %v, want %v", first, want)
+	}
+
+	want = []string{"-gitlabmr=https://gitlab.com/g/p/-/merge_requests/2", "-comment", "-report-dir=report/2026/10/18/09_30_15-job-8", "-reply"}
+	if !slices.Equal(second, want) {
+		t.Errorf("got %v, want %v", second, want)
+	}
+}
@@ -0,0 +1,164 @@
+// Package server receives the webhooks of GitHub and GitLab, and runs the reviews and replies they ask for
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/olbrichattila/qreview/internal/env"
+)
+
+const (
+	maxPayloadSize  = 25 << 20 // GitHub does not send larger payloads
+	shutdownTimeout = 10 * time.Second
+)
+
+// New creates the webhook server, the jobs are run by the runner
+func New(env env.EnvironmentManager, runner Runner) (*Server, error) {
+	if env == nil {
+		return nil, fmt.Errorf("environment manager should not be nil")
+	}
+
+	if env.WebhookSecret() == "" {
+		return nil, fmt.Errorf("please provide the webhook secret in your environment: `WEBHOOK_SECRET`")
+	}
+
+	return &Server{
+		secret:  []byte(env.WebhookSecret()),
+		mention: env.ReplyMention(),
+		queue:   newQueue(runner, env.ServeConcurrency(), env.ServeQueueSize()),
+	}, nil
+}
+
+// Server queues the jobs asked for by the webhooks, and runs them with a bounded concurrency
+type Server struct {
+	secret  []byte
+	mention string
+	queue   *queue
+}
+
+// Handler returns the routes of the server: POST /webhook receives the GitHub and GitLab events, GET /healthz tells it is up,
+// GET /queue lists the jobs. The jobs name private PRs, so the queue needs the webhook secret as a bearer token
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /webhook", s.webhook)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /queue", func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !hmac.Equal([]byte(token), s.secret) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("the queue needs the webhook secret as a bearer token"))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, s.queue.status())
+	})
+
+	return mux
+}
+
+// Run starts the jobs and listens on the address until the context is done,
+// then it stops taking events and waits for the running jobs
+func (s *Server) Run(ctx context.Context, addr string) error {
+	s.queue.start()
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Printf("Listening for webhooks on %s\n", addr)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		fmt.Println("Shutting down, waiting for the running jobs...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = httpServer.Shutdown(shutdownCtx)
+	}
+
+	s.queue.stop()
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// webhook verifies the event and queues its job. It answers 202 with the job, or 204 if the event asks for nothing
+func (s *Server) webhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	var job Job
+	var ok bool
+	switch {
+	case r.Header.Get("X-GitHub-Event") != "":
+		if err := verifyGitHub(s.secret, r.Header, body); err != nil {
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		job, ok, err = gitHubJob(r.Header.Get("X-GitHub-Event"), body, s.mention)
+	case r.Header.Get("X-Gitlab-Event") != "":
+		if err := verifyGitLab(s.secret, r.Header); err != nil {
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		job, ok, err = gitLabJob(r.Header.Get("X-Gitlab-Event"), body, s.mention)
+	default:
+		writeError(w, http.StatusBadRequest, errors.New("not a GitHub or GitLab event"))
+		return
+	}
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	queued, isNew, err := s.queue.push(job)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	if isNew {
+		fmt.Printf("Job %d queued: %s of %s (%s)\n", queued.ID, queued.Mode, queued.PRURL, queued.Event)
+	}
+
+	writeJSON(w, http.StatusAccepted, queued)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/olbrichattila/qreview/internal/pr"
+	"github.com/olbrichattila/qreview/internal/prcomment"
+)
+
+var errSignature = errors.New("invalid webhook signature")
+
+// verifyGitHub checks the HMAC SHA-256 signature GitHub sends in the X-Hub-Signature-256 header
+func verifyGitHub(secret []byte, header http.Header, body []byte) error {
+	signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return errSignature
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return errSignature
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errSignature
+	}
+
+	return nil
+}
+
+// verifyGitLab checks the secret token GitLab sends in the X-Gitlab-Token header, GitLab does not sign the payload
+func verifyGitLab(secret []byte, header http.Header) error {
+	if !hmac.Equal([]byte(header.Get("X-Gitlab-Token")), secret) {
+		return errSignature
+	}
+
+	return nil
+}
+
+// gitHubJob returns the job asked for by a GitHub event, false if there is none. Pull requests are reviewed when they are
+// opened or pushed to, a review comment mentioning qreview is answered, and a PR comment mentioning it asks for a new review
+func gitHubJob(event string, body []byte, mention string) (Job, bool, error) {
+	var payload struct {
+		Action      string `json:"action"`
+		PullRequest struct {
+			HTMLURL string `json:"html_url"`
+			Draft   bool   `json:"draft"`
+		} `json:"pull_request"`
+		Issue struct {
+			PullRequest *struct {
+				HTMLURL string `json:"html_url"`
+			} `json:"pull_request"`
+		} `json:"issue"`
+		Comment struct {
+			Body string `json:"body"`
+		} `json:"comment"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Job{}, false, fmt.Errorf("invalid GitHub %s payload: %w", event, err)
+	}
+
+	job := Job{Kind: pr.KindGitHub, Event: "github:" + event}
+	switch event {
+	case "pull_request":
+		if payload.PullRequest.Draft || !slices.Contains([]string{"opened", "reopened", "synchronize", "ready_for_review"}, payload.Action) {
+			return Job{}, false, nil
+		}
+		job.Mode, job.PRURL = ModeReview, payload.PullRequest.HTMLURL
+	case "pull_request_review_comment":
+		if payload.Action != "created" || !mentions(payload.Comment.Body, mention) {
+			return Job{}, false, nil
+		}
+		job.Mode, job.PRURL = ModeReply, payload.PullRequest.HTMLURL
+	case "issue_comment":
+		if payload.Action != "created" || payload.Issue.PullRequest == nil || !mentions(payload.Comment.Body, mention) {
+			return Job{}, false, nil
+		}
+		job.Mode, job.PRURL = ModeReview, payload.Issue.PullRequest.HTMLURL
+	default:
+		return Job{}, false, nil
+	}
+
+	if job.PRURL == "" {
+		return Job{}, false, fmt.Errorf("the GitHub %s payload has no pull request URL", event)
+	}
+
+	return job, true, nil
+}
+
+// gitLabJob returns the job asked for by a GitLab event, false if there is none. Merge requests are reviewed when they are
+// opened or pushed to, a diff note mentioning qreview is answered, and any other MR note mentioning it asks for a new review
+func gitLabJob(event string, body []byte, mention string) (Job, bool, error) {
+	var payload struct {
+		ObjectAttributes struct {
+			Action       string `json:"action"`
+			URL          string `json:"url"`
+			OldRev       string `json:"oldrev"` // Set on update events pushing new commits
+			Draft        bool   `json:"draft"`
+			NoteableType string `json:"noteable_type"`
+			Type         string `json:"type"`
+			Note         string `json:"note"`
+		} `json:"object_attributes"`
+		MergeRequest struct {
+			URL string `json:"url"`
+		} `json:"merge_request"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Job{}, false, fmt.Errorf("invalid GitLab %s payload: %w", event, err)
+	}
+
+	attributes := payload.ObjectAttributes
+	job := Job{Kind: pr.KindGitLab, Event: "gitlab:" + event}
+	switch event {
+	case "Merge Request Hook":
+		pushed := attributes.Action == "update" && attributes.OldRev != ""
+		if attributes.Draft || (attributes.Action != "open" && attributes.Action != "reopen" && !pushed) {
+			return Job{}, false, nil
+		}
+		job.Mode, job.PRURL = ModeReview, attributes.URL
+	case "Note Hook":
+		if attributes.NoteableType != "MergeRequest" || !mentions(attributes.Note, mention) {
+			return Job{}, false, nil
+		}
+		job.Mode, job.PRURL = ModeReview, payload.MergeRequest.URL
+		if attributes.Type == "DiffNote" {
+			job.Mode = ModeReply
+		}
+	default:
+		return Job{}, false, nil
+	}
+
+	if job.PRURL == "" {
+		return Job{}, false, fmt.Errorf("the GitLab %s payload has no merge request URL", event)
+	}
+
+	return job, true, nil
+}
+
+// mentions tells if the comment addresses qreview, its own comments never do
+func mentions(body, mention string) bool {
+	_, ok := prcomment.Mention(pr.Thread{Comments: []pr.ThreadComment{{Body: body}}}, mention)
+	return ok
+}
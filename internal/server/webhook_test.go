@@ -0,0 +1,203 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
+)
+
+const testSecret = "It's a Secret to Everybody"
+
+func readPayload(t *testing.T, name string) []byte {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
+
+func sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyGitHub(t *testing.T) {
+	body := readPayload(t, "github_pull_request.json")
+
+	tests := []struct {
+		name      string
+		signature string
+		wantErr   bool
+	}{
+		{"valid", sign(body), false},
+		{"signed with another secret", "sha256=" + strings.Repeat("ab", sha256.Size), true},
+		{"signed other body", sign(append(body, ' ')), true},
+		{"not hex", "sha256=zz", true},
+		{"sha1 only", "sha1=" + strings.Repeat("ab", 20), true},
+		{"missing", "", true},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		if test.signature != "" {
+			header.Set("X-Hub-Signature-256", test.signature)
+		}
+
+		if err := verifyGitHub([]byte(testSecret), header, body); (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v", test.name, err)
+		}
+	}
+}
+
+func TestVerifyGitLab(t *testing.T) {
+	for token, wantErr := range map[string]bool{testSecret: false, "wrong": true, "": true} {
+		header := http.Header{}
+		header.Set("X-Gitlab-Token", token)
+
+		if err := verifyGitLab([]byte(testSecret), header); (err != nil) != wantErr {
+			t.Errorf("token %q: got error %v", token, err)
+		}
+	}
+}
+
+func TestGitHubJobOfPullRequestEvent(t *testing.T) {
+	body := readPayload(t, "github_pull_request.json")
+
+	job, ok, err := gitHubJob("pull_request", body, "@qreview")
+	if err != nil || !ok {
+		t.Fatalf("got %t, %v, want a job", ok, err)
+	}
+
+	if job.Kind != pr.KindGitHub || job.Mode != ModeReview || job.PRURL != "https://github.com/octo-org/hello-world/pull/42" || job.Event != "github:pull_request" {
+		t.Errorf("got job %+v", job)
+	}
+
+	closed := strings.Replace(string(body), `"action": "synchronize"`, `"action": "closed"`, 1)
+	if _, ok, err := gitHubJob("pull_request", []byte(closed), "@qreview"); ok || err != nil {
+		t.Errorf("got %t, %v for a closed PR, want no job", ok, err)
+	}
+
+	draft := strings.Replace(string(body), `"draft": false`, `"draft": true`, 1)
+	if _, ok, err := gitHubJob("pull_request", []byte(draft), "@qreview"); ok || err != nil {
+		t.Errorf("got %t, %v for a draft PR, want no job", ok, err)
+	}
+
+	if _, _, err := gitHubJob("pull_request", []byte("{"), "@qreview"); err == nil {
+		t.Error("got no error for an invalid payload")
+	}
+}
+
+func TestGitLabJobOfMergeRequestEvents(t *testing.T) {
+	const mrURL = "https://gitlab.example.com/gitlabhq/gitlab-test/-/merge_requests/1"
+
+	job, ok, err := gitLabJob("Merge Request Hook", readPayload(t, "gitlab_merge_request.json"), "@qreview")
+	if err != nil || !ok {
+		t.Fatalf("got %t, %v, want a job", ok, err)
+	}
+
+	if job.Kind != pr.KindGitLab || job.Mode != ModeReview || job.PRURL != mrURL {
+		t.Errorf("got job %+v", job)
+	}
+
+	// An update without new commits, like a changed title, is not reviewed again
+	retitled := strings.Replace(string(readPayload(t, "gitlab_merge_request.json")), `"oldrev": "a76fd7e0e0f2f7fc5e8b8d3e0e1a9c3f2e1d0c9b"`, `"oldrev": ""`, 1)
+	if _, ok, err := gitLabJob("Merge Request Hook", []byte(retitled), "@qreview"); ok || err != nil {
+		t.Errorf("got %t, %v for a retitled MR, want no job", ok, err)
+	}
+
+	job, ok, err = gitLabJob("Note Hook", readPayload(t, "gitlab_note.json"), "@qreview")
+	if err != nil || !ok {
+		t.Fatalf("got %t, %v, want a job", ok, err)
+	}
+
+	if job.Mode != ModeReply || job.PRURL != mrURL {
+		t.Errorf("got job %+v, want a reply on the diff note", job)
+	}
+
+	if _, ok, _ := gitLabJob("Note Hook", readPayload(t, "gitlab_note.json"), "@someone"); ok {
+		t.Error("got a job for a note not mentioning qreview")
+	}
+}
+
+func TestWebhookQueuesVerifiedEvents(t *testing.T) {
+	t.Setenv(env.EnvWebhookSecret, testSecret)
+	t.Setenv(env.EnvReplyMention, "")
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The queue is not started, the jobs stay queued
+	server, err := New(envManager, &fakeRunner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := server.Handler()
+
+	body := readPayload(t, "github_pull_request.json")
+	post := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(string(body)))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		req.Header.Set("X-Hub-Signature-256", signature)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	if code := post("sha256=" + strings.Repeat("00", sha256.Size)); code != http.StatusUnauthorized {
+		t.Errorf("got status %d for a bad signature, want 401", code)
+	}
+
+	if code := post(sign(body)); code != http.StatusAccepted {
+		t.Errorf("got status %d for a signed event, want 202", code)
+	}
+
+	if status := server.queue.status(); status.Queued != 1 {
+		t.Errorf("got %d queued jobs, want 1", status.Queued)
+	}
+}
+
+func TestQueueNeedsTheSecret(t *testing.T) {
+	t.Setenv(env.EnvWebhookSecret, testSecret)
+	envManager, err := env.NewDotEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := New(envManager, &fakeRunner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for authorization, want := range map[string]int{
+		"":                     http.StatusUnauthorized,
+		"Bearer wrong":         http.StatusUnauthorized,
+		testSecret:             http.StatusUnauthorized,
+		"Bearer " + testSecret: http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/queue", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, req)
+		if recorder.Code != want {
+			t.Errorf("got status %d with authorization %q, want %d", recorder.Code, authorization, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
+)
+
+var (
+	cachedDiffFiles []pr.FileDiff
+	cachedDiffMu    sync.Mutex
+)
+
+// newPullRequest creates a source reading the files of a pull request of the forge
+func newPullRequest(env env.EnvironmentManager, kind pr.Kind, prURL string) (Source, error) {
+	if prURL == "" {
+		return nil, fmt.Errorf("the PR URL is missing")
+	}
+
+	if err := pr.ValidateURL(kind, prURL); err != nil {
+		return nil, err
+	}
+
+	return &pullRequest{
+		env:   env,
+		pr:    pr.New(env, kind),
+		prURL: prURL,
+	}, nil
+}
+
+type pullRequest struct {
+	env   env.EnvironmentManager
+	pr    pr.PullRequest
+	prURL string
+}
+
+// GetDiff implements Source.
+func (g *pullRequest) GetDiff(fileName string) (string, error) {
+	diffFiles, err := g.getDiffFiles()
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range diffFiles {
+		if f.Filename == fileName {
+			normalizedCode := strings.ReplaceAll(f.Patch, "\r\n", "\n")
+			return normalizedCode, nil
+		}
+	}
+
+	return "", fmt.Errorf("diff %s file not found", fileName)
+}
+
+// getDiffFiles fetches the PR diffs once, concurrent reviews wait for the first fetch
+func (g *pullRequest) getDiffFiles() ([]pr.FileDiff, error) {
+	cachedDiffMu.Lock()
+	defer cachedDiffMu.Unlock()
+
+	if cachedDiffFiles == nil {
+		diffFiles, err := g.pr.GetPRFileDiffs(g.prURL)
+		if err != nil {
+			return nil, err
+		}
+		cachedDiffFiles = diffFiles
+	}
+
+	return cachedDiffFiles, nil
+}
+
+// GetFile implements Source.
+func (g *pullRequest) GetFile(fileName string) (string, error) {
+	result, err := g.pr.GetPRFileContent(g.prURL, fileName)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ReplaceAll(result, "\r\n", "\n"), nil
+}
+
+// GetFiles implements Source.
+func (g *pullRequest) GetFiles() ([]string, error) {
+	return g.pr.GetPRFiles(g.prURL)
+}
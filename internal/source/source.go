@@ -1,11 +1,9 @@
-// Package source gets the data to be reviewed, from source, like local GIT or github. etc.
+// Package source gets the data to be reviewed, from source, like local GIT, GitHub, GitLab, Bitbucket or Gitea
 package source
 
 import (
-	"fmt"
-
-	cmdinterpreter "github.com/olbrichattila/qreview/internal/cmd-interpreter"
 	"github.com/olbrichattila/qreview/internal/env"
+	"github.com/olbrichattila/qreview/internal/pr"
 )
 
 // Source implement this interface for data sources
@@ -16,18 +14,14 @@ type Source interface {
 }
 
 func New(environment env.EnvironmentManager) (Source, error) {
-	if cmdinterpreter.HasFlag(cmdinterpreter.FlagGithubPR) {
-		return newFromCommandLine(environment)
+	kind, prURL, ok, err := pr.FromCommandLine(environment)
+	if err != nil {
+		return nil, err
 	}
 
-	return newLocalGit()
-}
-
-func newFromCommandLine(environment env.EnvironmentManager) (Source, error) {
-	prURL, err := cmdinterpreter.Flag(cmdinterpreter.FlagGithubPR)
-	if err != nil {
-		return nil, fmt.Errorf("cannot get %s flag from command line", cmdinterpreter.FlagGithubPR)
+	if ok {
+		return newPullRequest(environment, kind, prURL)
 	}
-	return newGitHub(environment, prURL)
 
+	return newLocalGit()
 }
@@ -3,9 +3,12 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/olbrichattila/qreview/cmd"
+	cmdinterpreter "github.com/olbrichattila/qreview/internal/cmd-interpreter"
 	"github.com/olbrichattila/qreview/internal/env"
 	"github.com/olbrichattila/qreview/internal/parentsummary"
 	"github.com/olbrichattila/qreview/internal/reportdefiner"
@@ -18,34 +21,69 @@ const (
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run executes the review and returns the process exit code, it is non zero if anything failed
+func run() int {
 	envManager, err := env.NewDotEnv()
 	if err != nil {
 		printErrors(err)
-		return
+		return 1
+	}
+
+	if command, _ := cmdinterpreter.Arg(0); command == cmdinterpreter.CommandServe {
+		return execute(cmd.NewServe(envManager))
+	}
+
+	if cmdinterpreter.HasFlag(cmdinterpreter.FlagReply) {
+		return execute(cmd.NewReply(envManager))
 	}
 
 	reportFolder := fmt.Sprintf("report/%s", time.Now().Format("2006/01/02/15_04"))
+	if folder, err := cmdinterpreter.Flag(cmdinterpreter.FlagReportDir); err == nil && folder != "" {
+		reportFolder = filepath.Clean(folder)
+	}
 	reviewers, err := reportdefiner.Load(envManager, "definitions.yaml", reportFolder)
 	if err != nil {
 		printErrors(err)
-		return
+		return 1
 	}
 
-	command, err := cmd.New(envManager, reviewers)
+	command, err := cmd.New(envManager, reviewers, reportFolder)
 	if err != nil {
 		printErrors(err)
-		return
+		return 1
 	}
 
+	exitCode := 0
 	if err := command.Execute(); err != nil {
 		printErrors(err)
+		exitCode = 1
 	}
 
 	err = parentsummary.Generate("report", reportFolder)
 	if err != nil {
 		printErrors(err)
-		return
+		return 1
+	}
+
+	return exitCode
+}
+
+// execute runs a command which makes no report, like the reply mode or the webhook server
+func execute(command cmd.CommandInterpreter, err error) int {
+	if err != nil {
+		printErrors(err)
+		return 1
 	}
+
+	if err := command.Execute(); err != nil {
+		printErrors(err)
+		return 1
+	}
+
+	return 0
 }
 
 func printErrors(err error) {